@@ -0,0 +1,108 @@
+package analysis
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	database "github.com/immatheus/gitback/databases"
+)
+
+// Commits made between LateNightStartHour and LateNightEndHour (exclusive) in the author's local time
+// count as late night
+const (
+	LateNightStartHour = 22
+	LateNightEndHour   = 6
+)
+
+// ActivityReport describes when commits happen, in the local time of whoever authored them
+type ActivityReport struct {
+	Punchcard        [7][24]int      `json:"punchcard"` // [weekday][hour], weekday 0 is Sunday
+	Timezones        []TimezoneCount `json:"timezones"`
+	WeekendCommits   int             `json:"weekendCommits"`
+	LateNightCommits int             `json:"lateNightCommits"`
+	WeekendRatio     float64         `json:"weekendRatio"`
+	LateNightRatio   float64         `json:"lateNightRatio"`
+}
+
+// TimezoneCount is how many contributors and commits come from a single UTC offset
+type TimezoneCount struct {
+	Offset       int    `json:"offset"` // minutes east of UTC
+	Label        string `json:"label"`
+	Contributors int    `json:"contributors"`
+	Commits      int    `json:"commits"`
+}
+
+// AnalyzeActivity builds the punchcard and timezone distribution for a set of commits
+func AnalyzeActivity(commits []database.CommitStats) ActivityReport {
+	report := ActivityReport{Timezones: []TimezoneCount{}}
+	if len(commits) == 0 {
+		return report
+	}
+
+	commitsByOffset := make(map[int]int)
+	authorOffsets := make(map[string]map[int]int)
+
+	for _, commit := range commits {
+		local := LocalTime(commit)
+		hour := local.Hour()
+		weekday := local.Weekday()
+
+		report.Punchcard[weekday][hour]++
+		if weekday == time.Saturday || weekday == time.Sunday {
+			report.WeekendCommits++
+		}
+		if hour >= LateNightStartHour || hour < LateNightEndHour {
+			report.LateNightCommits++
+		}
+
+		commitsByOffset[commit.TimezoneOffset]++
+		if authorOffsets[commit.Author] == nil {
+			authorOffsets[commit.Author] = make(map[int]int)
+		}
+		authorOffsets[commit.Author][commit.TimezoneOffset]++
+	}
+
+	// People travel and switch between DST, so each contributor is counted once in their most used offset
+	contributorsByOffset := make(map[int]int)
+	for _, offsets := range authorOffsets {
+		best, bestCount := 0, -1
+		for offset, count := range offsets {
+			if count > bestCount || (count == bestCount && offset < best) {
+				best, bestCount = offset, count
+			}
+		}
+		contributorsByOffset[best]++
+	}
+
+	for offset, count := range commitsByOffset {
+		report.Timezones = append(report.Timezones, TimezoneCount{
+			Offset:       offset,
+			Label:        formatOffset(offset),
+			Contributors: contributorsByOffset[offset],
+			Commits:      count,
+		})
+	}
+	sort.Slice(report.Timezones, func(i, j int) bool {
+		return report.Timezones[i].Offset < report.Timezones[j].Offset
+	})
+
+	report.WeekendRatio = float64(report.WeekendCommits) / float64(len(commits))
+	report.LateNightRatio = float64(report.LateNightCommits) / float64(len(commits))
+
+	return report
+}
+
+// LocalTime returns the commit time in the author's own timezone
+func LocalTime(commit database.CommitStats) time.Time {
+	return time.Unix(commit.Date, 0).In(time.FixedZone(formatOffset(commit.TimezoneOffset), commit.TimezoneOffset*60))
+}
+
+func formatOffset(offset int) string {
+	sign := "+"
+	if offset < 0 {
+		sign = "-"
+		offset = -offset
+	}
+	return fmt.Sprintf("UTC%s%02d:%02d", sign, offset/60, offset%60)
+}
@@ -0,0 +1,139 @@
+package analysis
+
+import (
+	"sort"
+
+	database "github.com/immatheus/gitback/databases"
+)
+
+// AuthorProfile is one author's contributions across every analyzed repository
+type AuthorProfile struct {
+	Names         []string      `json:"names"` // as written in commits, most used first
+	TotalRepos    int           `json:"totalRepos"`
+	TotalCommits  int           `json:"totalCommits"`
+	TotalAdded    int           `json:"totalAdded"`
+	TotalRemoved  int           `json:"totalRemoved"`
+	FirstActivity string        `json:"firstActivity,omitempty"` // YYYY-MM-DD
+	LastActivity  string        `json:"lastActivity,omitempty"`
+	LongestStreak Streak        `json:"longestStreak"`
+	Repos         []AuthorRepo  `json:"repos"`    // most commits first
+	Calendar      []CalendarDay `json:"calendar"` // days with commits in any repo, oldest first
+}
+
+// AuthorRepo is an author's contributions to one repository
+type AuthorRepo struct {
+	Username    string `json:"username"`
+	RepoName    string `json:"repoName"`
+	Commits     int    `json:"commits"`
+	Added       int    `json:"added"`
+	Removed     int    `json:"removed"`
+	FirstCommit string `json:"firstCommit"` // YYYY-MM-DD
+	LastCommit  string `json:"lastCommit"`
+}
+
+// CalendarDay is the commits an author made on one day of their local time
+type CalendarDay struct {
+	Date    string `json:"date"` // YYYY-MM-DD
+	Commits int    `json:"commits"`
+	Added   int    `json:"added"`
+	Removed int    `json:"removed"`
+}
+
+// AnalyzeAuthor combines an author's commits from every repository into a profile
+func AnalyzeAuthor(commits []database.AuthorCommit) AuthorProfile {
+	profile := AuthorProfile{
+		Names:    []string{},
+		Repos:    []AuthorRepo{},
+		Calendar: []CalendarDay{},
+	}
+	if len(commits) == 0 {
+		return profile
+	}
+
+	nameCounts := make(map[string]int)
+	repos := make(map[[2]string]*AuthorRepo)
+	calendar := make(map[int]*CalendarDay)
+	stats := make([]database.CommitStats, 0, len(commits))
+	firstDay, lastDay := dayIndex(commits[0].CommitStats), dayIndex(commits[0].CommitStats)
+
+	for _, commit := range commits {
+		profile.TotalCommits++
+		profile.TotalAdded += commit.Added
+		profile.TotalRemoved += commit.Removed
+		nameCounts[commit.Author]++
+		stats = append(stats, commit.CommitStats)
+
+		day := dayIndex(commit.CommitStats)
+		if day < firstDay {
+			firstDay = day
+		}
+		if day > lastDay {
+			lastDay = day
+		}
+
+		key := [2]string{commit.Username, commit.RepoName}
+		repo, ok := repos[key]
+		if !ok {
+			repo = &AuthorRepo{
+				Username:    commit.Username,
+				RepoName:    commit.RepoName,
+				FirstCommit: dayLabel(day),
+				LastCommit:  dayLabel(day),
+			}
+			repos[key] = repo
+		}
+		repo.Commits++
+		repo.Added += commit.Added
+		repo.Removed += commit.Removed
+		// Labels are YYYY-MM-DD so they compare in date order
+		if label := dayLabel(day); label < repo.FirstCommit {
+			repo.FirstCommit = label
+		} else if label > repo.LastCommit {
+			repo.LastCommit = label
+		}
+
+		calendarDay, ok := calendar[day]
+		if !ok {
+			calendarDay = &CalendarDay{Date: dayLabel(day)}
+			calendar[day] = calendarDay
+		}
+		calendarDay.Commits++
+		calendarDay.Added += commit.Added
+		calendarDay.Removed += commit.Removed
+	}
+
+	profile.FirstActivity = dayLabel(firstDay)
+	profile.LastActivity = dayLabel(lastDay)
+	profile.LongestStreak = longestStreak(commitDays(stats))
+
+	for name := range nameCounts {
+		profile.Names = append(profile.Names, name)
+	}
+	sort.Slice(profile.Names, func(i, j int) bool {
+		if nameCounts[profile.Names[i]] != nameCounts[profile.Names[j]] {
+			return nameCounts[profile.Names[i]] > nameCounts[profile.Names[j]]
+		}
+		return profile.Names[i] < profile.Names[j]
+	})
+
+	for _, repo := range repos {
+		profile.Repos = append(profile.Repos, *repo)
+	}
+	sort.Slice(profile.Repos, func(i, j int) bool {
+		a, b := profile.Repos[i], profile.Repos[j]
+		if a.Commits != b.Commits {
+			return a.Commits > b.Commits
+		}
+		return a.Username+"/"+a.RepoName < b.Username+"/"+b.RepoName
+	})
+	profile.TotalRepos = len(profile.Repos)
+
+	for _, day := range calendar {
+		profile.Calendar = append(profile.Calendar, *day)
+	}
+	sort.Slice(profile.Calendar, func(i, j int) bool {
+		return profile.Calendar[i].Date < profile.Calendar[j].Date
+	})
+
+	return profile
+}
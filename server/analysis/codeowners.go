@@ -0,0 +1,369 @@
+package analysis
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"math"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	database "github.com/immatheus/gitback/databases"
+	"github.com/immatheus/gitback/git"
+)
+
+// CodeOwnersLocations are the paths GitHub looks for a CODEOWNERS file, in order
+var CodeOwnersLocations = []string{".github/CODEOWNERS", "CODEOWNERS", "docs/CODEOWNERS"}
+
+// CodeOwnersOptions controls how ownership is weighted and when owners are considered stale
+type CodeOwnersOptions struct {
+	Depth        int     // how many directory levels to suggest owners for
+	MaxOwners    int     // owners suggested per directory
+	HalfLifeDays float64 // a change loses half its weight after this many days
+	StaleMonths  int     // existing owners with no changes in this window are flagged
+	Now          time.Time
+}
+
+// OwnerScore is a contributor's weighted share of changes in a directory
+type OwnerScore struct {
+	Owner      string  `json:"owner"`
+	Author     string  `json:"author"`
+	Email      string  `json:"email"`
+	Score      float64 `json:"score"`
+	Share      float64 `json:"share"`
+	Changes    int     `json:"changes"`
+	LastChange int64   `json:"lastChange"`
+}
+
+// DirectoryOwners lists the suggested owners of a single directory
+type DirectoryOwners struct {
+	Path   string       `json:"path"`
+	Owners []OwnerScore `json:"owners"`
+}
+
+// CodeOwnersRule is one parsed line of an existing CODEOWNERS file
+type CodeOwnersRule struct {
+	Line    int      `json:"line"`
+	Pattern string   `json:"pattern"`
+	Owners  []string `json:"owners"`
+}
+
+// StaleOwner is an existing owner who hasn't touched the paths they own recently
+type StaleOwner struct {
+	Line       int    `json:"line"`
+	Pattern    string `json:"pattern"`
+	Owner      string `json:"owner"`
+	LastChange int64  `json:"lastChange,omitempty"` // 0 when no change was found in the analyzed history
+}
+
+// CodeOwnersReport is the result of comparing history against an existing CODEOWNERS file
+type CodeOwnersReport struct {
+	Directories  []DirectoryOwners `json:"directories"`
+	Suggested    string            `json:"suggested"`
+	ExistingPath string            `json:"existingPath,omitempty"`
+	Existing     []CodeOwnersRule  `json:"existing,omitempty"`
+	StaleOwners  []StaleOwner      `json:"staleOwners"`
+	Unresolved   []string          `json:"unresolved,omitempty"` // teams and handles we can't map to commit authors
+}
+
+var noreplyEmail = regexp.MustCompile(`^(?:\d+\+)?([^@]+)@` + regexp.QuoteMeta(database.GitHubNoreplyDomain) + `$`)
+
+// SuggestCodeOwners builds a CODEOWNERS suggestion from per-path history and flags stale owners in existing
+func SuggestCodeOwners(changes []git.FileChange, existingPath string, existing []byte, opts CodeOwnersOptions) CodeOwnersReport {
+	if opts.Now.IsZero() {
+		opts.Now = time.Now()
+	}
+
+	report := CodeOwnersReport{
+		Directories:  suggestDirectoryOwners(changes, opts),
+		ExistingPath: existingPath,
+		StaleOwners:  []StaleOwner{},
+	}
+	report.Suggested = renderCodeOwners(report.Directories)
+
+	if existing != nil {
+		report.Existing = ParseCodeOwners(existing)
+		report.StaleOwners, report.Unresolved = findStaleOwners(changes, report.Existing, opts)
+	}
+
+	return report
+}
+
+func suggestDirectoryOwners(changes []git.FileChange, opts CodeOwnersOptions) []DirectoryOwners {
+	type key struct{ dir, email string }
+
+	scores := make(map[key]*OwnerScore)
+	totals := make(map[string]float64)
+	halfLife := opts.HalfLifeDays * 24 * float64(time.Hour/time.Second)
+
+	for _, change := range changes {
+		dir := directoryAtDepth(change.Path, opts.Depth)
+		email := strings.ToLower(change.Email)
+
+		// Renames and mode changes have no line counts but are still a sign of ownership
+		weight := float64(change.Added+change.Removed) + 1
+		if halfLife > 0 {
+			age := math.Max(0, float64(opts.Now.Unix()-change.Date))
+			weight *= math.Pow(0.5, age/halfLife)
+		}
+
+		k := key{dir, email}
+		score, ok := scores[k]
+		if !ok {
+			score = &OwnerScore{
+				Owner:  ownerHandle(change.Author, email),
+				Author: change.Author,
+				Email:  email,
+			}
+			scores[k] = score
+		}
+		score.Score += weight
+		score.Changes++
+		if change.Date > score.LastChange {
+			score.LastChange = change.Date
+		}
+		totals[dir] += weight
+	}
+
+	byDir := make(map[string][]OwnerScore)
+	for k, score := range scores {
+		if totals[k.dir] > 0 {
+			score.Share = score.Score / totals[k.dir]
+		}
+		byDir[k.dir] = append(byDir[k.dir], *score)
+	}
+
+	directories := make([]DirectoryOwners, 0, len(byDir))
+	for dir, owners := range byDir {
+		sort.Slice(owners, func(i, j int) bool {
+			if owners[i].Score != owners[j].Score {
+				return owners[i].Score > owners[j].Score
+			}
+			return owners[i].Email < owners[j].Email
+		})
+		if len(owners) > opts.MaxOwners {
+			owners = owners[:opts.MaxOwners]
+		}
+		directories = append(directories, DirectoryOwners{Path: dir, Owners: owners})
+	}
+
+	// Parents sort before children so more specific rules come later and win, like in CODEOWNERS itself
+	sort.Slice(directories, func(i, j int) bool {
+		return directories[i].Path < directories[j].Path
+	})
+
+	return directories
+}
+
+// directoryAtDepth returns the directory of a file truncated to depth levels, "/" for the repo root
+func directoryAtDepth(filePath string, depth int) string {
+	dir := path.Dir(filePath)
+	if dir == "." || depth <= 0 {
+		return "/"
+	}
+
+	parts := strings.Split(dir, "/")
+	if len(parts) > depth {
+		parts = parts[:depth]
+	}
+	return "/" + strings.Join(parts, "/") + "/"
+}
+
+// ownerHandle prefers a GitHub handle when the email is a noreply address, since those map to a user directly
+func ownerHandle(author, email string) string {
+	if m := noreplyEmail.FindStringSubmatch(email); m != nil {
+		return "@" + m[1]
+	}
+	if email != "" {
+		return email
+	}
+	return author
+}
+
+func renderCodeOwners(directories []DirectoryOwners) string {
+	var b strings.Builder
+	b.WriteString("# Generated by GitBack from recent commit history\n")
+
+	for _, dir := range directories {
+		if len(dir.Owners) == 0 {
+			continue
+		}
+		pattern := dir.Path
+		if pattern == "/" {
+			pattern = "*"
+		}
+
+		owners := make([]string, len(dir.Owners))
+		for i, owner := range dir.Owners {
+			owners[i] = owner.Owner
+		}
+		fmt.Fprintf(&b, "%s %s\n", pattern, strings.Join(owners, " "))
+	}
+
+	return b.String()
+}
+
+// ParseCodeOwners parses a CODEOWNERS file, skipping comments and blank lines
+func ParseCodeOwners(data []byte) []CodeOwnersRule {
+	var rules []CodeOwnersRule
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+
+		fields := strings.Fields(stripCodeOwnersComment(scanner.Text()))
+		if len(fields) == 0 {
+			continue
+		}
+
+		rules = append(rules, CodeOwnersRule{
+			Line:    lineNumber,
+			Pattern: fields[0],
+			Owners:  fields[1:],
+		})
+	}
+
+	return rules
+}
+
+// stripCodeOwnersComment cuts the comment off a CODEOWNERS line. A # starts one at the start of the line or
+// after whitespace, \# is a literal # in a path
+func stripCodeOwnersComment(line string) string {
+	var b strings.Builder
+	for i := 0; i < len(line); i++ {
+		switch {
+		case strings.HasPrefix(line[i:], `\#`):
+			b.WriteByte('#')
+			i++
+		case line[i] == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return b.String()
+		default:
+			b.WriteByte(line[i])
+		}
+	}
+	return b.String()
+}
+
+func findStaleOwners(changes []git.FileChange, rules []CodeOwnersRule, opts CodeOwnersOptions) ([]StaleOwner, []string) {
+	cutoff := opts.Now.AddDate(0, -opts.StaleMonths, 0).Unix()
+
+	// Group changes by path so every pattern only has to be matched once per file
+	byPath := make(map[string][]int)
+	for i, change := range changes {
+		byPath[change.Path] = append(byPath[change.Path], i)
+	}
+
+	stale := []StaleOwner{}
+	unresolved := make(map[string]bool)
+
+	for _, rule := range rules {
+		matcher, err := compileCodeOwnersPattern(rule.Pattern)
+		if err != nil {
+			continue
+		}
+
+		var matched []int
+		for p, indexes := range byPath {
+			if matcher.MatchString(p) {
+				matched = append(matched, indexes...)
+			}
+		}
+
+		for _, owner := range rule.Owners {
+			// Teams can't be resolved to a single commit author
+			if strings.HasPrefix(owner, "@") && strings.Contains(owner, "/") {
+				unresolved[owner] = true
+				continue
+			}
+
+			var lastChange int64
+			for _, i := range matched {
+				if ownerMatchesAuthor(owner, changes[i]) && changes[i].Date > lastChange {
+					lastChange = changes[i].Date
+				}
+			}
+
+			if lastChange < cutoff {
+				stale = append(stale, StaleOwner{
+					Line:       rule.Line,
+					Pattern:    rule.Pattern,
+					Owner:      owner,
+					LastChange: lastChange,
+				})
+			}
+		}
+	}
+
+	unresolvedList := make([]string, 0, len(unresolved))
+	for owner := range unresolved {
+		unresolvedList = append(unresolvedList, owner)
+	}
+	sort.Strings(unresolvedList)
+
+	return stale, unresolvedList
+}
+
+// ownerMatchesAuthor maps a CODEOWNERS entry to a commit author. Emails match exactly, handles match the
+// noreply address, the email's local part or the author name, which is the best we can do without the GitHub API
+func ownerMatchesAuthor(owner string, change git.FileChange) bool {
+	email := strings.ToLower(change.Email)
+
+	if !strings.HasPrefix(owner, "@") {
+		return strings.EqualFold(owner, email)
+	}
+
+	handle := strings.ToLower(owner[1:])
+	if m := noreplyEmail.FindStringSubmatch(email); m != nil && strings.ToLower(m[1]) == handle {
+		return true
+	}
+	if local, _, ok := strings.Cut(email, "@"); ok && local == handle {
+		return true
+	}
+	return strings.EqualFold(change.Author, handle)
+}
+
+// compileCodeOwnersPattern converts a gitignore style CODEOWNERS pattern into a regexp over repo paths
+func compileCodeOwnersPattern(pattern string) (*regexp.Regexp, error) {
+	// A slash at the start or in the middle anchors the pattern to the repo root
+	anchored := strings.HasPrefix(pattern, "/") || strings.Contains(strings.TrimSuffix(pattern, "/"), "/")
+	pattern = strings.TrimPrefix(pattern, "/")
+	pattern = strings.TrimSuffix(pattern, "/")
+
+	var b strings.Builder
+	if anchored {
+		b.WriteString("^")
+	} else {
+		b.WriteString("^(?:.*/)?")
+	}
+
+	for i := 0; i < len(pattern); i++ {
+		switch {
+		case strings.HasPrefix(pattern[i:], "**/"):
+			b.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case pattern[i] == '*':
+			b.WriteString("[^/]*")
+		case pattern[i] == '?':
+			b.WriteString("[^/]")
+		default:
+			b.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+
+	// A pattern naming a directory owns everything below it, one with a wildcard in its last segment like
+	// docs/* or *.go only matches what it names
+	if lastSegment := pattern[strings.LastIndex(pattern, "/")+1:]; strings.Contains(lastSegment, "*") {
+		b.WriteString("$")
+	} else {
+		b.WriteString("(?:/.*)?$")
+	}
+
+	return regexp.Compile(b.String())
+}
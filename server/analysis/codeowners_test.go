@@ -0,0 +1,98 @@
+package analysis
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/immatheus/gitback/git"
+)
+
+func TestParseCodeOwners(t *testing.T) {
+	data := []byte(`# Owners of everything
+*       @octo/core
+
+/docs/  @mona docs@example.com # docs team
+	
+src/\#generated/  @hubot
+src/issue#12.md @mona
+`)
+
+	want := []CodeOwnersRule{
+		{Line: 2, Pattern: "*", Owners: []string{"@octo/core"}},
+		{Line: 4, Pattern: "/docs/", Owners: []string{"@mona", "docs@example.com"}},
+		{Line: 6, Pattern: "src/#generated/", Owners: []string{"@hubot"}},
+		{Line: 7, Pattern: "src/issue#12.md", Owners: []string{"@mona"}},
+	}
+	if got := ParseCodeOwners(data); !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseCodeOwners returned\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestCompileCodeOwnersPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		match   []string
+		noMatch []string
+	}{
+		{"*", []string{"README.md", "src/main.go"}, nil},
+		{"*.go", []string{"main.go", "cmd/tool/main.go"}, []string{"main.gox", "go/README.md"}},
+		{"/build/", []string{"build/out.txt", "build/a/b.txt"}, []string{"src/build/out.txt", "builder/out.txt"}},
+		{"apps/", []string{"apps/web/index.ts", "src/apps/web/index.ts"}, []string{"apps.txt"}},
+		{"docs/*", []string{"docs/index.md"}, []string{"docs/a/b.md", "src/docs/index.md"}},
+		{"docs/*.md", []string{"docs/index.md"}, []string{"docs/a/b.md"}},
+		{"**/logs", []string{"logs/today.log", "deep/dir/logs/today.log"}, []string{"logsearch/x"}},
+		{"docs/**", []string{"docs/index.md", "docs/a/b.md"}, []string{"other/docs/a.md"}},
+		{"src/lib", []string{"src/lib", "src/lib/util.go"}, []string{"other/src/lib/util.go", "src/library.go"}},
+		{"file?.txt", []string{"file1.txt", "a/fileA.txt"}, []string{"file10.txt", "file/.txt"}},
+	}
+	for _, test := range tests {
+		matcher, err := compileCodeOwnersPattern(test.pattern)
+		if err != nil {
+			t.Fatalf("compileCodeOwnersPattern(%q) failed: %v", test.pattern, err)
+		}
+		for _, path := range test.match {
+			if !matcher.MatchString(path) {
+				t.Errorf("%q doesn't match %s", test.pattern, path)
+			}
+		}
+		for _, path := range test.noMatch {
+			if matcher.MatchString(path) {
+				t.Errorf("%q matches %s", test.pattern, path)
+			}
+		}
+	}
+}
+
+func TestFindStaleOwners(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	recent := now.AddDate(0, -1, 0).Unix()
+	old := now.AddDate(-2, 0, 0).Unix()
+
+	changes := []git.FileChange{
+		{Author: "Mona", Email: "1+mona@users.noreply.github.com", Date: recent, Path: "docs/index.md"},
+		{Author: "Hubot", Email: "hubot@example.com", Date: old, Path: "docs/guides/setup.md"},
+		{Author: "Hubot", Email: "hubot@example.com", Date: recent, Path: "src/main.go"},
+		{Author: "Octocat", Email: "cat@example.com", Date: old, Path: "src/main.go"},
+	}
+	rules := []CodeOwnersRule{
+		{Line: 1, Pattern: "docs/*", Owners: []string{"@mona", "@hubot"}},
+		{Line: 2, Pattern: "/src/", Owners: []string{"@hubot", "cat@example.com", "@octo/core"}},
+		{Line: 3, Pattern: "/missing/", Owners: []string{"@mona"}},
+	}
+
+	stale, unresolved := findStaleOwners(changes, rules, CodeOwnersOptions{StaleMonths: 6, Now: now})
+
+	want := []StaleOwner{
+		// docs/* doesn't own docs/guides/setup.md, so hubot never changed what the rule covers
+		{Line: 1, Pattern: "docs/*", Owner: "@hubot"},
+		{Line: 2, Pattern: "/src/", Owner: "cat@example.com", LastChange: old},
+		{Line: 3, Pattern: "/missing/", Owner: "@mona"},
+	}
+	if !reflect.DeepEqual(stale, want) {
+		t.Errorf("findStaleOwners returned\n%+v\nwant\n%+v", stale, want)
+	}
+	if !reflect.DeepEqual(unresolved, []string{"@octo/core"}) {
+		t.Errorf("unresolved = %v, want the team", unresolved)
+	}
+}
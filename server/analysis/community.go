@@ -0,0 +1,113 @@
+package analysis
+
+import (
+	"sort"
+
+	database "github.com/immatheus/gitback/databases"
+)
+
+// CoreCommitShare is the share of all commits the core team accounts for. Core contributors are the
+// smallest group of most active authors reaching it, everyone else is occasional
+const CoreCommitShare = 0.8
+
+// CommunityReport splits contributors into core, occasional and drive-by contributors
+type CommunityReport struct {
+	CoreContributors       []string           `json:"coreContributors"`
+	OccasionalContributors int                `json:"occasionalContributors"`
+	DriveByContributors    int                `json:"driveByContributors"` // authors with a single commit
+	CoreShare              float64            `json:"coreShare"`
+	Months                 []MonthlyCommunity `json:"months"`
+}
+
+// MonthlyCommunity is the community breakdown of a single month
+type MonthlyCommunity struct {
+	Month               string  `json:"month"` // YYYY-MM, UTC
+	Commits             int     `json:"commits"`
+	ActiveContributors  int     `json:"activeContributors"`
+	NewContributors     int     `json:"newContributors"`
+	DriveByContributors int     `json:"driveByContributors"` // new this month and never committed again
+	CoreCommits         int     `json:"coreCommits"`
+	OccasionalCommits   int     `json:"occasionalCommits"`
+	CoreShare           float64 `json:"coreShare"`
+}
+
+func analyzeCommunity(commits []database.CommitStats, authors map[string]*contributorActivity) CommunityReport {
+	report := CommunityReport{
+		CoreContributors: []string{},
+		Months:           []MonthlyCommunity{},
+	}
+
+	ranked := make([]ContributorLifecycle, 0, len(authors))
+	for _, activity := range authors {
+		ranked = append(ranked, activity.lifecycle)
+		if activity.lifecycle.Commits == 1 {
+			report.DriveByContributors++
+		}
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].Commits != ranked[j].Commits {
+			return ranked[i].Commits > ranked[j].Commits
+		}
+		return ranked[i].Author < ranked[j].Author
+	})
+
+	core := make(map[string]bool)
+	coreCommits := 0
+	for _, contributor := range ranked {
+		if float64(coreCommits) >= CoreCommitShare*float64(len(commits)) {
+			break
+		}
+		core[contributor.Author] = true
+		coreCommits += contributor.Commits
+		report.CoreContributors = append(report.CoreContributors, contributor.Author)
+	}
+	report.OccasionalContributors = len(ranked) - len(core)
+	if len(commits) > 0 {
+		report.CoreShare = float64(coreCommits) / float64(len(commits))
+	}
+
+	months := make(map[int]*MonthlyCommunity)
+	active := make(map[int]map[string]bool)
+	monthFor := func(index int) *MonthlyCommunity {
+		month, ok := months[index]
+		if !ok {
+			month = &MonthlyCommunity{Month: monthLabel(index)}
+			months[index] = month
+			active[index] = make(map[string]bool)
+		}
+		return month
+	}
+
+	for _, commit := range commits {
+		index := monthIndex(commit.Date)
+		month := monthFor(index)
+		month.Commits++
+		active[index][commit.Author] = true
+		if core[commit.Author] {
+			month.CoreCommits++
+		} else {
+			month.OccasionalCommits++
+		}
+	}
+
+	for _, activity := range authors {
+		month := monthFor(monthIndex(activity.lifecycle.FirstCommit))
+		month.NewContributors++
+		if activity.lifecycle.Commits == 1 {
+			month.DriveByContributors++
+		}
+	}
+
+	for index, month := range months {
+		month.ActiveContributors = len(active[index])
+		if month.Commits > 0 {
+			month.CoreShare = float64(month.CoreCommits) / float64(month.Commits)
+		}
+		report.Months = append(report.Months, *month)
+	}
+	sort.Slice(report.Months, func(i, j int) bool {
+		return report.Months[i].Month < report.Months[j].Month
+	})
+
+	return report
+}
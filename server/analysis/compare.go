@@ -0,0 +1,161 @@
+package analysis
+
+import (
+	"time"
+
+	database "github.com/immatheus/gitback/databases"
+)
+
+const (
+	// compareRecentWindow is the span the recent velocity, contributors and churn are measured over
+	compareRecentWindow = 52 * 7 * 24 * time.Hour
+	weekSeconds         = 7 * 24 * 60 * 60
+)
+
+// CompareInput is one repository to compare
+type CompareInput struct {
+	Username string
+	RepoName string
+	Commits  []database.CommitStats
+}
+
+// Comparison puts several repositories side by side, their monthly series share the Months axis
+type Comparison struct {
+	Months []string         `json:"months"` // YYYY-MM, UTC, from the oldest first commit to now
+	Repos  []RepoComparison `json:"repos"`  // in the order requested
+}
+
+// RepoComparison is one repository's metrics, normalized so repos of different size and age compare
+type RepoComparison struct {
+	Username          string `json:"username"`
+	RepoName          string `json:"repoName"`
+	FirstCommit       int64  `json:"firstCommit,omitempty"`
+	LastCommit        int64  `json:"lastCommit,omitempty"`
+	TotalCommits      int    `json:"totalCommits"`
+	TotalContributors int    `json:"totalContributors"`
+	Lines             int    `json:"lines"`
+
+	// CommitsPerWeek averages over the repo's whole life, RecentCommitsPerWeek over the last 52 weeks
+	CommitsPerWeek       float64 `json:"commitsPerWeek"`
+	RecentCommitsPerWeek float64 `json:"recentCommitsPerWeek"`
+	// RecentContributors is how many authors committed in the last 52 weeks
+	RecentContributors int `json:"recentContributors"`
+	// ChurnRatio is the lines added and removed in the last 52 weeks per line of code the repo has now
+	ChurnRatio float64 `json:"churnRatio"`
+
+	Commits      []int `json:"commits"`      // per month
+	Contributors []int `json:"contributors"` // active per month
+	LocGrowth    []int `json:"locGrowth"`    // lines of code at the end of each month
+	Churn        []int `json:"churn"`        // lines added plus removed per month
+}
+
+// CompareRepos computes each repository's metrics over a shared monthly axis ending in the month of now
+func CompareRepos(repos []CompareInput, now time.Time) Comparison {
+	comparison := Comparison{
+		Months: []string{},
+		Repos:  make([]RepoComparison, 0, len(repos)),
+	}
+
+	last := monthIndex(now.Unix())
+	first := last
+	for _, repo := range repos {
+		for _, commit := range repo.Commits {
+			if index := monthIndex(commit.Date); index < first {
+				first = index
+			}
+		}
+	}
+
+	// LOC is read at the last second of each month
+	monthEnds := make([]int64, 0, last-first+1)
+	for index := first; index <= last; index++ {
+		comparison.Months = append(comparison.Months, monthLabel(index))
+		monthEnds = append(monthEnds, monthStart(index+1)-1)
+	}
+
+	recentSince := now.Add(-compareRecentWindow).Unix()
+	for _, repo := range repos {
+		comparison.Repos = append(comparison.Repos, compareRepo(repo, first, monthEnds, recentSince, now))
+	}
+
+	return comparison
+}
+
+func compareRepo(repo CompareInput, first int, monthEnds []int64, recentSince int64, now time.Time) RepoComparison {
+	result := RepoComparison{
+		Username:     repo.Username,
+		RepoName:     repo.RepoName,
+		TotalCommits: len(repo.Commits),
+		Commits:      make([]int, len(monthEnds)),
+		Contributors: make([]int, len(monthEnds)),
+		LocGrowth:    database.CalculateLinesAt(repo.Commits, monthEnds),
+		Churn:        make([]int, len(monthEnds)),
+	}
+	if len(repo.Commits) == 0 {
+		return result
+	}
+
+	authors := make(map[string]bool)
+	recentAuthors := make(map[string]bool)
+	monthAuthors := make([]map[string]bool, len(monthEnds))
+	recentCommits, recentChurn := 0, 0
+	result.FirstCommit, result.LastCommit = repo.Commits[0].Date, repo.Commits[0].Date
+
+	for _, commit := range repo.Commits {
+		result.Lines += commit.Added - commit.Removed
+		authors[commit.Author] = true
+		if commit.Date < result.FirstCommit {
+			result.FirstCommit = commit.Date
+		}
+		if commit.Date > result.LastCommit {
+			result.LastCommit = commit.Date
+		}
+
+		if commit.Date >= recentSince {
+			recentCommits++
+			recentChurn += commit.Added + commit.Removed
+			recentAuthors[commit.Author] = true
+		}
+
+		// Commits dated in the future fall off the end of the axis
+		month := monthIndex(commit.Date) - first
+		if month >= len(monthEnds) {
+			continue
+		}
+		result.Commits[month]++
+		result.Churn[month] += commit.Added + commit.Removed
+		if monthAuthors[month] == nil {
+			monthAuthors[month] = make(map[string]bool)
+		}
+		monthAuthors[month][commit.Author] = true
+	}
+
+	for month, active := range monthAuthors {
+		result.Contributors[month] = len(active)
+	}
+	result.TotalContributors = len(authors)
+	result.RecentContributors = len(recentAuthors)
+
+	// Repos younger than a week count as a week old so one commit doesn't read as a huge velocity
+	lifetimeWeeks := float64(now.Unix()-result.FirstCommit) / weekSeconds
+	if lifetimeWeeks < 1 {
+		lifetimeWeeks = 1
+	}
+	result.CommitsPerWeek = float64(len(repo.Commits)) / lifetimeWeeks
+
+	recentWeeks := compareRecentWindow.Seconds() / weekSeconds
+	if lifetimeWeeks < recentWeeks {
+		recentWeeks = lifetimeWeeks
+	}
+	result.RecentCommitsPerWeek = float64(recentCommits) / recentWeeks
+
+	if result.Lines > 0 {
+		result.ChurnRatio = float64(recentChurn) / float64(result.Lines)
+	}
+
+	return result
+}
+
+func monthStart(index int) int64 {
+	return time.Date(index/12, time.Month(index%12+1), 1, 0, 0, 0, 0, time.UTC).Unix()
+}
@@ -0,0 +1,162 @@
+package analysis
+
+import (
+	"sort"
+	"time"
+
+	database "github.com/immatheus/gitback/databases"
+)
+
+// RetentionOffsets are the months after a contributor's first commit at which cohorts are measured
+var RetentionOffsets = []int{3, 6, 12}
+
+// ContributorLifecycle summarizes one author's activity over the life of the repo
+type ContributorLifecycle struct {
+	Author       string `json:"author"`
+	FirstCommit  int64  `json:"firstCommit"`
+	LastCommit   int64  `json:"lastCommit"`
+	Commits      int    `json:"commits"`
+	ActiveMonths int    `json:"activeMonths"`
+	TenureDays   int    `json:"tenureDays"`
+}
+
+// CohortRetention tracks the contributors who made their first commit in a given month
+type CohortRetention struct {
+	Month           string           `json:"month"` // YYYY-MM, UTC
+	NewContributors int              `json:"newContributors"`
+	Retention       []RetentionPoint `json:"retention"`
+}
+
+// RetentionPoint is how many of a cohort committed again exactly Offset months after joining. Offsets past
+// the repo's last commit are left out since they can't be observed yet
+type RetentionPoint struct {
+	Offset   int     `json:"offset"`
+	Retained int     `json:"retained"`
+	Rate     float64 `json:"rate"`
+}
+
+// ContributorReport is the contributor lifecycle section of an analysis
+type ContributorReport struct {
+	Contributors []ContributorLifecycle `json:"contributors"`
+	Cohorts      []CohortRetention      `json:"cohorts"`
+	Community    CommunityReport        `json:"community"`
+}
+
+type contributorActivity struct {
+	lifecycle ContributorLifecycle
+	months    map[int]bool
+}
+
+// AnalyzeContributors computes per-author lifecycles, monthly cohort retention and the community breakdown
+func AnalyzeContributors(commits []database.CommitStats) ContributorReport {
+	report := ContributorReport{
+		Contributors: []ContributorLifecycle{},
+		Cohorts:      []CohortRetention{},
+		Community:    analyzeCommunity(nil, nil),
+	}
+	if len(commits) == 0 {
+		return report
+	}
+
+	authors := collectContributorActivity(commits)
+
+	lastMonth := 0
+	for _, activity := range authors {
+		if month := monthIndex(activity.lifecycle.LastCommit); month > lastMonth {
+			lastMonth = month
+		}
+	}
+
+	cohorts := make(map[int][]*contributorActivity)
+	for _, activity := range authors {
+		activity.lifecycle.ActiveMonths = len(activity.months)
+		activity.lifecycle.TenureDays = int((activity.lifecycle.LastCommit - activity.lifecycle.FirstCommit) / 86400)
+		report.Contributors = append(report.Contributors, activity.lifecycle)
+
+		first := monthIndex(activity.lifecycle.FirstCommit)
+		cohorts[first] = append(cohorts[first], activity)
+	}
+
+	sort.Slice(report.Contributors, func(i, j int) bool {
+		if report.Contributors[i].FirstCommit != report.Contributors[j].FirstCommit {
+			return report.Contributors[i].FirstCommit < report.Contributors[j].FirstCommit
+		}
+		return report.Contributors[i].Author < report.Contributors[j].Author
+	})
+
+	for month, members := range cohorts {
+		cohort := CohortRetention{
+			Month:           monthLabel(month),
+			NewContributors: len(members),
+			Retention:       []RetentionPoint{},
+		}
+
+		for _, offset := range RetentionOffsets {
+			if month+offset > lastMonth {
+				break
+			}
+
+			retained := 0
+			for _, member := range members {
+				if member.months[month+offset] {
+					retained++
+				}
+			}
+			cohort.Retention = append(cohort.Retention, RetentionPoint{
+				Offset:   offset,
+				Retained: retained,
+				Rate:     float64(retained) / float64(len(members)),
+			})
+		}
+
+		report.Cohorts = append(report.Cohorts, cohort)
+	}
+
+	sort.Slice(report.Cohorts, func(i, j int) bool {
+		return report.Cohorts[i].Month < report.Cohorts[j].Month
+	})
+
+	report.Community = analyzeCommunity(commits, authors)
+
+	return report
+}
+
+func collectContributorActivity(commits []database.CommitStats) map[string]*contributorActivity {
+	authors := make(map[string]*contributorActivity)
+
+	for _, commit := range commits {
+		activity, ok := authors[commit.Author]
+		if !ok {
+			activity = &contributorActivity{
+				lifecycle: ContributorLifecycle{
+					Author:      commit.Author,
+					FirstCommit: commit.Date,
+					LastCommit:  commit.Date,
+				},
+				months: make(map[int]bool),
+			}
+			authors[commit.Author] = activity
+		}
+
+		activity.lifecycle.Commits++
+		if commit.Date < activity.lifecycle.FirstCommit {
+			activity.lifecycle.FirstCommit = commit.Date
+		}
+		if commit.Date > activity.lifecycle.LastCommit {
+			activity.lifecycle.LastCommit = commit.Date
+		}
+		activity.months[monthIndex(commit.Date)] = true
+	}
+
+	return authors
+}
+
+// monthIndex numbers calendar months (UTC) so that consecutive months differ by one
+func monthIndex(timestamp int64) int {
+	t := time.Unix(timestamp, 0).UTC()
+	return t.Year()*12 + int(t.Month()) - 1
+}
+
+func monthLabel(index int) string {
+	return time.Date(index/12, time.Month(index%12+1), 1, 0, 0, 0, 0, time.UTC).Format("2006-01")
+}
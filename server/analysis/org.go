@@ -0,0 +1,269 @@
+package analysis
+
+import (
+	"sort"
+
+	database "github.com/immatheus/gitback/databases"
+)
+
+// OrgTopContributors is how many contributors the org report ranks
+const OrgTopContributors = 25
+
+// OrgRepoInput is one analyzed repository of an org along with what GitHub says about it
+type OrgRepoInput struct {
+	Name     string
+	Language string
+	Stars    int
+	Size     int // KB, as reported by GitHub
+	Commits  []database.CommitStats
+}
+
+// OrgReport is the combined analysis of every repository of a GitHub org or user
+type OrgReport struct {
+	Org               string           `json:"org"`
+	TotalRepos        int              `json:"totalRepos"`
+	TotalCommits      int              `json:"totalCommits"`
+	TotalAdded        int              `json:"totalAdded"`
+	TotalRemoved      int              `json:"totalRemoved"`
+	TotalContributors int              `json:"totalContributors"` // distinct author names across repos
+	TotalStars        int              `json:"totalStars"`
+	Activity          []OrgMonth       `json:"activity"` // every month from the first commit to the last, UTC
+	TopContributors   []OrgContributor `json:"topContributors"`
+	Languages         []LanguageShare  `json:"languages"` // largest first
+	Repos             []OrgRepo        `json:"repos"`     // most commits first
+	// Failed lists the repos that couldn't be analyzed and are left out of the totals
+	Failed []string `json:"failed"`
+}
+
+// OrgMonth is the commits made across an org's repos in one month
+type OrgMonth struct {
+	Month              string `json:"month"` // YYYY-MM
+	Commits            int    `json:"commits"`
+	Added              int    `json:"added"`
+	Removed            int    `json:"removed"`
+	ActiveRepos        int    `json:"activeRepos"`
+	ActiveContributors int    `json:"activeContributors"`
+}
+
+// OrgContributor is an author's contributions across an org's repos
+type OrgContributor struct {
+	Author  string `json:"author"`
+	Commits int    `json:"commits"`
+	Added   int    `json:"added"`
+	Removed int    `json:"removed"`
+	Repos   int    `json:"repos"`
+}
+
+// LanguageShare is how much of an org is written in a language, going by each repo's primary language
+type LanguageShare struct {
+	Language string  `json:"language"`
+	Repos    int     `json:"repos"`
+	Size     int     `json:"size"`  // KB
+	Share    float64 `json:"share"` // of the total size, 0 to 1
+}
+
+// OrgRepo is one repository's line in the org report
+type OrgRepo struct {
+	Name         string `json:"name"`
+	Language     string `json:"language,omitempty"`
+	Stars        int    `json:"stars"`
+	Commits      int    `json:"commits"`
+	Added        int    `json:"added"`
+	Removed      int    `json:"removed"`
+	Contributors int    `json:"contributors"`
+	LastCommit   int64  `json:"lastCommit,omitempty"`
+}
+
+type orgMonthTally struct {
+	month        OrgMonth
+	repos        map[string]bool
+	contributors map[string]bool
+}
+
+// OrgAggregator folds an org's repositories into a report one at a time, so each repo's commits can be
+// dropped once they're counted. It isn't safe for concurrent use
+type OrgAggregator struct {
+	org              string
+	repos            []OrgRepo
+	failed           []string
+	totalStars       int
+	months           map[int]*orgMonthTally
+	contributors     map[string]*OrgContributor
+	contributorRepos map[string]map[string]bool
+	languages        map[string]*LanguageShare
+	totalSize        int
+}
+
+// NewOrgAggregator starts an empty report for org
+func NewOrgAggregator(org string) *OrgAggregator {
+	return &OrgAggregator{
+		org:              org,
+		repos:            []OrgRepo{},
+		failed:           []string{},
+		months:           make(map[int]*orgMonthTally),
+		contributors:     make(map[string]*OrgContributor),
+		contributorRepos: make(map[string]map[string]bool),
+		languages:        make(map[string]*LanguageShare),
+	}
+}
+
+// AnalyzeOrg combines the analyses of an org's repositories into one report
+func AnalyzeOrg(org string, repos []OrgRepoInput) OrgReport {
+	aggregator := NewOrgAggregator(org)
+	for _, repo := range repos {
+		aggregator.Add(repo)
+	}
+	return aggregator.Report()
+}
+
+// Add counts one analyzed repository, it keeps no reference to repo.Commits
+func (a *OrgAggregator) Add(repo OrgRepoInput) {
+	summary := OrgRepo{Name: repo.Name, Language: repo.Language, Stars: repo.Stars, Commits: len(repo.Commits)}
+	authors := make(map[string]bool)
+
+	for _, commit := range repo.Commits {
+		summary.Added += commit.Added
+		summary.Removed += commit.Removed
+		if commit.Date > summary.LastCommit {
+			summary.LastCommit = commit.Date
+		}
+		authors[commit.Author] = true
+
+		index := monthIndex(commit.Date)
+		tally, ok := a.months[index]
+		if !ok {
+			tally = &orgMonthTally{
+				month:        OrgMonth{Month: monthLabel(index)},
+				repos:        make(map[string]bool),
+				contributors: make(map[string]bool),
+			}
+			a.months[index] = tally
+		}
+		tally.month.Commits++
+		tally.month.Added += commit.Added
+		tally.month.Removed += commit.Removed
+		tally.repos[repo.Name] = true
+		tally.contributors[commit.Author] = true
+
+		contributor, ok := a.contributors[commit.Author]
+		if !ok {
+			contributor = &OrgContributor{Author: commit.Author}
+			a.contributors[commit.Author] = contributor
+			a.contributorRepos[commit.Author] = make(map[string]bool)
+		}
+		contributor.Commits++
+		contributor.Added += commit.Added
+		contributor.Removed += commit.Removed
+		a.contributorRepos[commit.Author][repo.Name] = true
+	}
+	summary.Contributors = len(authors)
+
+	a.totalStars += repo.Stars
+	a.repos = append(a.repos, summary)
+
+	language := repo.Language
+	if language == "" {
+		language = "Other"
+	}
+	share, ok := a.languages[language]
+	if !ok {
+		share = &LanguageShare{Language: language}
+		a.languages[language] = share
+	}
+	share.Repos++
+	share.Size += repo.Size
+	a.totalSize += repo.Size
+}
+
+// Fail records a repository that couldn't be analyzed
+func (a *OrgAggregator) Fail(name string) {
+	a.failed = append(a.failed, name)
+}
+
+// Report builds the report from the repositories added so far
+func (a *OrgAggregator) Report() OrgReport {
+	report := OrgReport{
+		Org:               a.org,
+		TotalRepos:        len(a.repos),
+		TotalContributors: len(a.contributors),
+		TotalStars:        a.totalStars,
+		Activity:          []OrgMonth{},
+		TopContributors:   []OrgContributor{},
+		Languages:         []LanguageShare{},
+		Repos:             append([]OrgRepo{}, a.repos...),
+		Failed:            append([]string{}, a.failed...),
+	}
+
+	for _, repo := range report.Repos {
+		report.TotalCommits += repo.Commits
+		report.TotalAdded += repo.Added
+		report.TotalRemoved += repo.Removed
+	}
+	sort.Slice(report.Repos, func(i, j int) bool {
+		if report.Repos[i].Commits != report.Repos[j].Commits {
+			return report.Repos[i].Commits > report.Repos[j].Commits
+		}
+		return report.Repos[i].Name < report.Repos[j].Name
+	})
+	sort.Strings(report.Failed)
+
+	if len(a.months) > 0 {
+		first, last := -1, -1
+		for index := range a.months {
+			if first == -1 || index < first {
+				first = index
+			}
+			if index > last {
+				last = index
+			}
+		}
+		// Months without commits are kept so the series can be charted as is
+		for index := first; index <= last; index++ {
+			tally, ok := a.months[index]
+			if !ok {
+				report.Activity = append(report.Activity, OrgMonth{Month: monthLabel(index)})
+				continue
+			}
+			month := tally.month
+			month.ActiveRepos = len(tally.repos)
+			month.ActiveContributors = len(tally.contributors)
+			report.Activity = append(report.Activity, month)
+		}
+	}
+
+	for author, contributor := range a.contributors {
+		top := *contributor
+		top.Repos = len(a.contributorRepos[author])
+		report.TopContributors = append(report.TopContributors, top)
+	}
+	sort.Slice(report.TopContributors, func(i, j int) bool {
+		a, b := report.TopContributors[i], report.TopContributors[j]
+		if a.Commits != b.Commits {
+			return a.Commits > b.Commits
+		}
+		return a.Author < b.Author
+	})
+	if len(report.TopContributors) > OrgTopContributors {
+		report.TopContributors = report.TopContributors[:OrgTopContributors]
+	}
+
+	for _, share := range a.languages {
+		language := *share
+		if a.totalSize > 0 {
+			language.Share = float64(language.Size) / float64(a.totalSize)
+		}
+		report.Languages = append(report.Languages, language)
+	}
+	sort.Slice(report.Languages, func(i, j int) bool {
+		a, b := report.Languages[i], report.Languages[j]
+		if a.Size != b.Size {
+			return a.Size > b.Size
+		}
+		if a.Repos != b.Repos {
+			return a.Repos > b.Repos
+		}
+		return a.Language < b.Language
+	})
+
+	return report
+}
@@ -0,0 +1,103 @@
+package analysis
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	database "github.com/immatheus/gitback/databases"
+)
+
+func orgTestRepos() []OrgRepoInput {
+	jan := time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC).Unix()
+	mar := time.Date(2024, 3, 5, 12, 0, 0, 0, time.UTC).Unix()
+
+	return []OrgRepoInput{
+		{Name: "api", Language: "Go", Stars: 10, Size: 300, Commits: []database.CommitStats{
+			{Author: "mona", Date: jan, Added: 10, Removed: 2},
+			{Author: "hubot", Date: mar, Added: 5},
+		}},
+		{Name: "web", Language: "TypeScript", Stars: 5, Size: 100, Commits: []database.CommitStats{
+			{Author: "mona", Date: mar, Added: 7, Removed: 7},
+		}},
+		{Name: "docs", Stars: 1, Size: 0, Commits: []database.CommitStats{}},
+	}
+}
+
+func TestAnalyzeOrg(t *testing.T) {
+	report := AnalyzeOrg("octo", orgTestRepos())
+
+	if report.Org != "octo" || report.TotalRepos != 3 || report.TotalCommits != 3 || report.TotalStars != 16 {
+		t.Errorf("totals: %+v", report)
+	}
+	if report.TotalAdded != 22 || report.TotalRemoved != 9 || report.TotalContributors != 2 {
+		t.Errorf("added %d, removed %d, contributors %d, want 22, 9 and 2",
+			report.TotalAdded, report.TotalRemoved, report.TotalContributors)
+	}
+
+	wantActivity := []OrgMonth{
+		{Month: "2024-01", Commits: 1, Added: 10, Removed: 2, ActiveRepos: 1, ActiveContributors: 1},
+		{Month: "2024-02"},
+		{Month: "2024-03", Commits: 2, Added: 12, Removed: 7, ActiveRepos: 2, ActiveContributors: 2},
+	}
+	if !reflect.DeepEqual(report.Activity, wantActivity) {
+		t.Errorf("activity = %+v, want %+v", report.Activity, wantActivity)
+	}
+
+	wantContributors := []OrgContributor{
+		{Author: "mona", Commits: 2, Added: 17, Removed: 9, Repos: 2},
+		{Author: "hubot", Commits: 1, Added: 5, Repos: 1},
+	}
+	if !reflect.DeepEqual(report.TopContributors, wantContributors) {
+		t.Errorf("top contributors = %+v, want %+v", report.TopContributors, wantContributors)
+	}
+
+	if len(report.Languages) != 3 || report.Languages[0].Language != "Go" || report.Languages[0].Share != 0.75 ||
+		report.Languages[2].Language != "Other" {
+		t.Errorf("languages = %+v, want Go, TypeScript then Other", report.Languages)
+	}
+
+	var names []string
+	for _, repo := range report.Repos {
+		names = append(names, repo.Name)
+	}
+	if want := []string{"api", "web", "docs"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("repos ordered %v, want %v", names, want)
+	}
+	if report.Repos[0].Contributors != 2 || report.Repos[0].LastCommit == 0 {
+		t.Errorf("api summary = %+v", report.Repos[0])
+	}
+}
+
+// TestOrgAggregatorOrder adds repos in the order they finish, which mustn't change the report
+func TestOrgAggregatorOrder(t *testing.T) {
+	repos := orgTestRepos()
+	want := AnalyzeOrg("octo", repos)
+	want.Failed = []string{"broken", "gone"}
+
+	aggregator := NewOrgAggregator("octo")
+	aggregator.Fail("gone")
+	for i := len(repos) - 1; i >= 0; i-- {
+		aggregator.Add(repos[i])
+		if i == 1 {
+			aggregator.Fail("broken")
+		}
+	}
+
+	got := aggregator.Report()
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("report in reverse order\n%+v\nwant\n%+v", got, want)
+	}
+	// Building the report leaves the aggregator as it was
+	if again := aggregator.Report(); !reflect.DeepEqual(again, got) {
+		t.Errorf("second report differs\n%+v\nwant\n%+v", again, got)
+	}
+}
+
+func TestAnalyzeOrgEmpty(t *testing.T) {
+	report := AnalyzeOrg("octo", nil)
+	if report.TotalRepos != 0 || report.Activity == nil || report.TopContributors == nil ||
+		report.Languages == nil || report.Repos == nil || report.Failed == nil {
+		t.Errorf("empty report = %+v, want zero totals and empty lists", report)
+	}
+}
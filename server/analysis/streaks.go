@@ -0,0 +1,175 @@
+package analysis
+
+import (
+	"sort"
+	"time"
+
+	database "github.com/immatheus/gitback/databases"
+)
+
+// Streak is a run of consecutive calendar days with at least one commit
+type Streak struct {
+	Days  int    `json:"days"`
+	Start string `json:"start,omitempty"` // YYYY-MM-DD
+	End   string `json:"end,omitempty"`
+}
+
+// dayIndex numbers calendar days in the author's local time so that consecutive days differ by one
+func dayIndex(commit database.CommitStats) int {
+	local := LocalTime(commit)
+	return int(time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.UTC).Unix() / 86400)
+}
+
+func dayLabel(index int) string {
+	return time.Unix(int64(index)*86400, 0).UTC().Format("2006-01-02")
+}
+
+// commitDays returns the sorted, distinct days the given commits were made on
+func commitDays(commits []database.CommitStats) []int {
+	seen := make(map[int]bool)
+	days := make([]int, 0)
+	for _, commit := range commits {
+		day := dayIndex(commit)
+		if !seen[day] {
+			seen[day] = true
+			days = append(days, day)
+		}
+	}
+	sort.Ints(days)
+	return days
+}
+
+// longestStreak finds the longest run of consecutive days in a sorted list of distinct days
+func longestStreak(days []int) Streak {
+	if len(days) == 0 {
+		return Streak{}
+	}
+
+	bestStart, bestLength := days[0], 1
+	runStart, runLength := days[0], 1
+	for i := 1; i < len(days); i++ {
+		if days[i] == days[i-1]+1 {
+			runLength++
+		} else {
+			runStart, runLength = days[i], 1
+		}
+		if runLength > bestLength {
+			bestStart, bestLength = runStart, runLength
+		}
+	}
+
+	return Streak{
+		Days:  bestLength,
+		Start: dayLabel(bestStart),
+		End:   dayLabel(bestStart + bestLength - 1),
+	}
+}
+
+// LongestGapsReported is how many of the longest inactive periods are returned
+const LongestGapsReported = 5
+
+// Gap is a run of days without any commit, between two days that had one
+type Gap struct {
+	Days  int    `json:"days"`
+	Start string `json:"start"` // first day without commits, YYYY-MM-DD
+	End   string `json:"end"`   // last day without commits
+}
+
+// AuthorStreaks are the streaks of a single contributor
+type AuthorStreaks struct {
+	Author        string `json:"author"`
+	LongestStreak Streak `json:"longestStreak"`
+	CurrentStreak Streak `json:"currentStreak"`
+}
+
+// StreakReport is the streak and inactivity section of an analysis. Days are calendar days in the
+// local time of each commit's author
+type StreakReport struct {
+	LongestStreak Streak          `json:"longestStreak"`
+	CurrentStreak Streak          `json:"currentStreak"` // the streak ending on the last commit day
+	LongestGaps   []Gap           `json:"longestGaps"`
+	Authors       []AuthorStreaks `json:"authors"`
+}
+
+// AnalyzeStreaks computes the repo and per author commit streaks and the longest inactive periods
+func AnalyzeStreaks(commits []database.CommitStats) StreakReport {
+	days := commitDays(commits)
+
+	report := StreakReport{
+		LongestStreak: longestStreak(days),
+		CurrentStreak: currentStreak(days),
+		LongestGaps:   longestGaps(days, LongestGapsReported),
+		Authors:       []AuthorStreaks{},
+	}
+
+	byAuthor := make(map[string][]database.CommitStats)
+	for _, commit := range commits {
+		byAuthor[commit.Author] = append(byAuthor[commit.Author], commit)
+	}
+
+	for author, authorCommits := range byAuthor {
+		authorDays := commitDays(authorCommits)
+		report.Authors = append(report.Authors, AuthorStreaks{
+			Author:        author,
+			LongestStreak: longestStreak(authorDays),
+			CurrentStreak: currentStreak(authorDays),
+		})
+	}
+	sort.Slice(report.Authors, func(i, j int) bool {
+		if report.Authors[i].LongestStreak.Days != report.Authors[j].LongestStreak.Days {
+			return report.Authors[i].LongestStreak.Days > report.Authors[j].LongestStreak.Days
+		}
+		return report.Authors[i].Author < report.Authors[j].Author
+	})
+
+	return report
+}
+
+// currentStreak is the run of consecutive days ending on the last day in a sorted list of distinct days
+func currentStreak(days []int) Streak {
+	if len(days) == 0 {
+		return Streak{}
+	}
+
+	start := len(days) - 1
+	for start > 0 && days[start-1] == days[start]-1 {
+		start--
+	}
+
+	return Streak{
+		Days:  len(days) - start,
+		Start: dayLabel(days[start]),
+		End:   dayLabel(days[len(days)-1]),
+	}
+}
+
+// longestGaps returns the limit longest runs of inactive days between commit days, longest first
+func longestGaps(days []int, limit int) []Gap {
+	type gap struct{ start, length int }
+
+	gaps := make([]gap, 0)
+	for i := 1; i < len(days); i++ {
+		if length := days[i] - days[i-1] - 1; length > 0 {
+			gaps = append(gaps, gap{start: days[i-1] + 1, length: length})
+		}
+	}
+	sort.Slice(gaps, func(i, j int) bool {
+		if gaps[i].length != gaps[j].length {
+			return gaps[i].length > gaps[j].length
+		}
+		return gaps[i].start < gaps[j].start
+	})
+	if len(gaps) > limit {
+		gaps = gaps[:limit]
+	}
+
+	result := make([]Gap, len(gaps))
+	for i, g := range gaps {
+		result[i] = Gap{
+			Days:  g.length,
+			Start: dayLabel(g.start),
+			End:   dayLabel(g.start + g.length - 1),
+		}
+	}
+	return result
+}
@@ -0,0 +1,131 @@
+package analysis
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	database "github.com/immatheus/gitback/databases"
+)
+
+// TrendingWindow is how far back views count and how fast they lose weight
+type TrendingWindow struct {
+	Length   time.Duration
+	HalfLife time.Duration
+}
+
+// TrendingWindows are the windows trending can be ranked over, by name
+var TrendingWindows = map[string]TrendingWindow{
+	"24h": {Length: 24 * time.Hour, HalfLife: 12 * time.Hour},
+	"7d":  {Length: 7 * 24 * time.Hour, HalfLife: 2 * 24 * time.Hour},
+}
+
+// TrendingRepo is a repo ranked by how much its views grew over the window
+type TrendingRepo struct {
+	Username      string  `json:"username"`
+	RepoName      string  `json:"repoName"`
+	Views         int     `json:"views"`         // in the window
+	PreviousViews int     `json:"previousViews"` // in the window of the same length before it
+	Score         float64 `json:"score"`
+}
+
+// RankTrending scores repos by their decayed views in the window minus what they would have scored had
+// they kept the level of the previous window, so steady traffic doesn't count as trending. Views are
+// counted per day, a day only partly in a window counts in proportion
+func RankTrending(views []database.DailyViews, window TrendingWindow, now time.Time) []TrendingRepo {
+	windowStart := now.Add(-window.Length)
+	previousStart := windowStart.Add(-window.Length)
+
+	type tally struct {
+		recent, previous, decayed float64
+	}
+	tallies := make(map[[2]string]*tally)
+
+	for _, day := range views {
+		start := day.Day.UTC()
+		end := start.Add(24 * time.Hour)
+		if end.After(now) {
+			end = now
+		}
+		if !end.After(start) {
+			continue
+		}
+
+		key := [2]string{day.Username, day.RepoName}
+		t, ok := tallies[key]
+		if !ok {
+			t = &tally{}
+			tallies[key] = t
+		}
+
+		// Views are assumed spread evenly over the part of the day that has passed
+		perHour := float64(day.Views) / end.Sub(start).Hours()
+
+		if recent := overlap(start, end, windowStart, now); recent > 0 {
+			t.recent += perHour * recent.Hours()
+
+			midpoint := maxTime(start, windowStart).Add(recent / 2)
+			t.decayed += perHour * recent.Hours() * decay(now.Sub(midpoint), window.HalfLife)
+		}
+		if previous := overlap(start, end, previousStart, windowStart); previous > 0 {
+			t.previous += perHour * previous.Hours()
+		}
+	}
+
+	// Average weight of a view spread evenly over the window, what steady traffic is discounted by
+	meanDecay := window.HalfLife.Hours() / (window.Length.Hours() * math.Ln2) *
+		(1 - decay(window.Length, window.HalfLife))
+
+	repos := make([]TrendingRepo, 0, len(tallies))
+	for key, t := range tallies {
+		if t.recent < 0.5 {
+			continue
+		}
+		repos = append(repos, TrendingRepo{
+			Username:      key[0],
+			RepoName:      key[1],
+			Views:         int(math.Round(t.recent)),
+			PreviousViews: int(math.Round(t.previous)),
+			Score:         math.Round((t.decayed-t.previous*meanDecay)*100) / 100,
+		})
+	}
+
+	sort.Slice(repos, func(i, j int) bool {
+		if repos[i].Score != repos[j].Score {
+			return repos[i].Score > repos[j].Score
+		}
+		if repos[i].Views != repos[j].Views {
+			return repos[i].Views > repos[j].Views
+		}
+		return repos[i].Username+"/"+repos[i].RepoName < repos[j].Username+"/"+repos[j].RepoName
+	})
+
+	return repos
+}
+
+// overlap returns how much of [start, end) falls within [from, to)
+func overlap(start, end, from, to time.Time) time.Duration {
+	start, end = maxTime(start, from), minTime(end, to)
+	if !end.After(start) {
+		return 0
+	}
+	return end.Sub(start)
+}
+
+func decay(age, halfLife time.Duration) float64 {
+	return math.Pow(0.5, age.Hours()/halfLife.Hours())
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,146 @@
+package analysis
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	database "github.com/immatheus/gitback/databases"
+)
+
+// dailyViews gives repo views on each of the days before now, the last value being the day before now
+func dailyViews(repo string, now time.Time, perDay ...int) []database.DailyViews {
+	views := make([]database.DailyViews, 0, len(perDay))
+	for i, count := range perDay {
+		views = append(views, database.DailyViews{
+			Username: "octo",
+			RepoName: repo,
+			Day:      now.AddDate(0, 0, i-len(perDay)),
+			Views:    count,
+		})
+	}
+	return views
+}
+
+func rankByName(repos []TrendingRepo) map[string]TrendingRepo {
+	byName := make(map[string]TrendingRepo, len(repos))
+	for _, repo := range repos {
+		byName[repo.RepoName] = repo
+	}
+	return byName
+}
+
+func TestRankTrendingCounts(t *testing.T) {
+	now := time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)
+
+	repos := RankTrending(dailyViews("hello", now, 4, 10), TrendingWindows["24h"], now)
+	if len(repos) != 1 {
+		t.Fatalf("RankTrending returned %d repos, want 1", len(repos))
+	}
+	if repos[0].Username != "octo" || repos[0].Views != 10 || repos[0].PreviousViews != 4 {
+		t.Errorf("got %+v, want 10 views and 4 previous views", repos[0])
+	}
+}
+
+func TestRankTrendingSteadyTraffic(t *testing.T) {
+	now := time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)
+	steady := make([]int, 14)
+	for i := range steady {
+		steady[i] = 100
+	}
+
+	repos := RankTrending(dailyViews("steady", now, steady...), TrendingWindows["7d"], now)
+	if len(repos) != 1 {
+		t.Fatalf("RankTrending returned %d repos, want 1", len(repos))
+	}
+	// A week of 700 views scores next to nothing when the week before had as many
+	if math.Abs(repos[0].Score) > 10 {
+		t.Errorf("steady traffic scored %v, want about 0", repos[0].Score)
+	}
+}
+
+func TestRankTrendingOrder(t *testing.T) {
+	now := time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)
+
+	var views []database.DailyViews
+	views = append(views, dailyViews("growing", now, 10, 10, 10, 10, 10, 10, 10, 20, 30, 40, 50, 60, 70, 80)...)
+	views = append(views, dailyViews("steady", now, 50, 50, 50, 50, 50, 50, 50, 50, 50, 50, 50, 50, 50, 50)...)
+	views = append(views, dailyViews("shrinking", now, 90, 90, 90, 90, 90, 90, 90, 80, 70, 60, 50, 40, 30, 20)...)
+	// Gone quiet in the current window, it isn't trending at all
+	views = append(views, dailyViews("quiet", now, 500, 500, 500, 500, 500, 500, 500, 0, 0, 0, 0, 0, 0, 0)...)
+
+	repos := RankTrending(views, TrendingWindows["7d"], now)
+
+	var order []string
+	for _, repo := range repos {
+		order = append(order, repo.RepoName)
+	}
+	want := []string{"growing", "steady", "shrinking"}
+	if len(order) != len(want) {
+		t.Fatalf("RankTrending returned %v, want %v", order, want)
+	}
+	for i := range want {
+		if order[i] != want[i] {
+			t.Fatalf("RankTrending returned %v, want %v", order, want)
+		}
+	}
+	if repos[0].Score <= 0 || repos[2].Score >= 0 {
+		t.Errorf("growing scored %v and shrinking %v, want positive and negative", repos[0].Score, repos[2].Score)
+	}
+}
+
+func TestRankTrendingRecentViewsWeighMore(t *testing.T) {
+	now := time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)
+
+	var views []database.DailyViews
+	views = append(views, dailyViews("yesterday", now, 0, 0, 0, 0, 0, 0, 100)...)
+	views = append(views, dailyViews("last-week", now, 100, 0, 0, 0, 0, 0, 0)...)
+
+	repos := rankByName(RankTrending(views, TrendingWindows["7d"], now))
+	yesterday, lastWeek := repos["yesterday"], repos["last-week"]
+	if yesterday.Views != lastWeek.Views {
+		t.Fatalf("views = %d and %d, want the same", yesterday.Views, lastWeek.Views)
+	}
+	// Two days half life: six days apart is three halvings
+	if ratio := yesterday.Score / lastWeek.Score; math.Abs(ratio-8) > 0.1 {
+		t.Errorf("score ratio = %v, want 8", ratio)
+	}
+}
+
+func TestRankTrendingPartialDays(t *testing.T) {
+	now := time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)
+
+	views := []database.DailyViews{
+		// Today so far, all within the last 24h
+		{Username: "octo", RepoName: "today", Day: now.Truncate(24 * time.Hour), Views: 10},
+		// Yesterday, half of it within the last 24h
+		{Username: "octo", RepoName: "yesterday", Day: now.Truncate(24*time.Hour).AddDate(0, 0, -1), Views: 10},
+		// Not started yet
+		{Username: "octo", RepoName: "tomorrow", Day: now.Truncate(24*time.Hour).AddDate(0, 0, 1), Views: 10},
+	}
+
+	repos := rankByName(RankTrending(views, TrendingWindows["24h"], now))
+	if len(repos) != 2 {
+		t.Fatalf("RankTrending returned %v, want today and yesterday", repos)
+	}
+	if repos["today"].Views != 10 || repos["today"].PreviousViews != 0 {
+		t.Errorf("today = %+v, want 10 views", repos["today"])
+	}
+	if repos["yesterday"].Views != 5 || repos["yesterday"].PreviousViews != 5 {
+		t.Errorf("yesterday = %+v, want 5 views in each window", repos["yesterday"])
+	}
+}
+
+func TestRankTrendingTies(t *testing.T) {
+	now := time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)
+
+	var views []database.DailyViews
+	for _, repo := range []string{"b", "c", "a"} {
+		views = append(views, dailyViews(repo, now, 5)...)
+	}
+
+	repos := RankTrending(views, TrendingWindows["24h"], now)
+	if len(repos) != 3 || repos[0].RepoName != "a" || repos[1].RepoName != "b" || repos[2].RepoName != "c" {
+		t.Errorf("tied repos ranked %+v, want by name", repos)
+	}
+}
@@ -0,0 +1,199 @@
+package analysis
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	database "github.com/immatheus/gitback/databases"
+)
+
+const (
+	wrappedTopContributors = 5
+	wrappedTopWords        = 20
+)
+
+// stopWords are left out of the most common words, they'd top every repo's list otherwise
+var stopWords = map[string]bool{
+	"the": true, "and": true, "for": true, "with": true, "from": true, "into": true,
+	"this": true, "that": true, "are": true, "was": true, "not": true, "when": true,
+}
+
+var nonWordChars = regexp.MustCompile(`[^\w\s]`)
+
+// WrappedReport is a compact year in review of a repository
+type WrappedReport struct {
+	Year              int                   `json:"year"`
+	TotalCommits      int                   `json:"totalCommits"`
+	TotalAdded        int                   `json:"totalAdded"`
+	TotalRemoved      int                   `json:"totalRemoved"`
+	TotalContributors int                   `json:"totalContributors"`
+	TopContributor    *WrappedContributor   `json:"topContributor"`
+	TopContributors   []WrappedContributor  `json:"topContributors"`
+	BusiestWeek       *WrappedPeriod        `json:"busiestWeek"`
+	BusiestDay        *WrappedPeriod        `json:"busiestDay"`
+	BiggestCommit     *database.CommitStats `json:"biggestCommit"`
+	LongestStreak     Streak                `json:"longestStreak"`
+	TopWords          []WordCount           `json:"topWords"`
+	NewContributors   []string              `json:"newContributors"` // first ever commit landed this year
+}
+
+// WrappedContributor is one author's totals for the year
+type WrappedContributor struct {
+	Author  string `json:"author"`
+	Commits int    `json:"commits"`
+	Added   int    `json:"added"`
+	Removed int    `json:"removed"`
+}
+
+// WrappedPeriod is the activity of a single day or week, Start is YYYY-MM-DD
+type WrappedPeriod struct {
+	Start   string `json:"start"`
+	Commits int    `json:"commits"`
+	Added   int    `json:"added"`
+	Removed int    `json:"removed"`
+}
+
+// WordCount is how often a word appears in commit messages
+type WordCount struct {
+	Word  string `json:"word"`
+	Count int    `json:"count"`
+}
+
+// AnalyzeWrapped computes the year in review highlights. All commits are needed, not just the year's,
+// to tell which contributors are new
+func AnalyzeWrapped(commits []database.CommitStats, year int) WrappedReport {
+	report := WrappedReport{
+		Year:            year,
+		TopContributors: []WrappedContributor{},
+		TopWords:        []WordCount{},
+		NewContributors: []string{},
+	}
+
+	firstYear := make(map[string]int)
+	yearCommits := make([]database.CommitStats, 0)
+	for _, commit := range commits {
+		commitYear := LocalTime(commit).Year()
+		if first, ok := firstYear[commit.Author]; !ok || commitYear < first {
+			firstYear[commit.Author] = commitYear
+		}
+		if commitYear == year {
+			yearCommits = append(yearCommits, commit)
+		}
+	}
+
+	if len(yearCommits) == 0 {
+		return report
+	}
+
+	contributors := make(map[string]*WrappedContributor)
+	days := make(map[int]*WrappedPeriod)
+	weeks := make(map[int]*WrappedPeriod)
+	words := make(map[string]int)
+
+	for i, commit := range yearCommits {
+		report.TotalCommits++
+		report.TotalAdded += commit.Added
+		report.TotalRemoved += commit.Removed
+
+		contributor, ok := contributors[commit.Author]
+		if !ok {
+			contributor = &WrappedContributor{Author: commit.Author}
+			contributors[commit.Author] = contributor
+		}
+		contributor.Commits++
+		contributor.Added += commit.Added
+		contributor.Removed += commit.Removed
+
+		day := dayIndex(commit)
+		addToPeriod(days, day, commit)
+		addToPeriod(weeks, weekStart(day), commit)
+
+		if report.BiggestCommit == nil || commitSize(commit) > commitSize(*report.BiggestCommit) {
+			report.BiggestCommit = &yearCommits[i]
+		}
+
+		for _, word := range strings.Fields(nonWordChars.ReplaceAllString(strings.ToLower(commit.Message), " ")) {
+			if len(word) > 2 && !stopWords[word] {
+				words[word]++
+			}
+		}
+	}
+
+	report.TotalContributors = len(contributors)
+
+	ranked := make([]WrappedContributor, 0, len(contributors))
+	for author, contributor := range contributors {
+		ranked = append(ranked, *contributor)
+		if firstYear[author] == year {
+			report.NewContributors = append(report.NewContributors, author)
+		}
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].Commits != ranked[j].Commits {
+			return ranked[i].Commits > ranked[j].Commits
+		}
+		return ranked[i].Author < ranked[j].Author
+	})
+	sort.Strings(report.NewContributors)
+
+	if len(ranked) > wrappedTopContributors {
+		ranked = ranked[:wrappedTopContributors]
+	}
+	report.TopContributors = ranked
+	report.TopContributor = &ranked[0]
+
+	report.BusiestDay = busiestPeriod(days)
+	report.BusiestWeek = busiestPeriod(weeks)
+	report.LongestStreak = longestStreak(commitDays(yearCommits))
+
+	for word, count := range words {
+		// Same as the word cloud, a word needs to show up at least twice
+		if count > 1 {
+			report.TopWords = append(report.TopWords, WordCount{Word: word, Count: count})
+		}
+	}
+	sort.Slice(report.TopWords, func(i, j int) bool {
+		if report.TopWords[i].Count != report.TopWords[j].Count {
+			return report.TopWords[i].Count > report.TopWords[j].Count
+		}
+		return report.TopWords[i].Word < report.TopWords[j].Word
+	})
+	if len(report.TopWords) > wrappedTopWords {
+		report.TopWords = report.TopWords[:wrappedTopWords]
+	}
+
+	return report
+}
+
+func addToPeriod(periods map[int]*WrappedPeriod, index int, commit database.CommitStats) {
+	period, ok := periods[index]
+	if !ok {
+		period = &WrappedPeriod{Start: dayLabel(index)}
+		periods[index] = period
+	}
+	period.Commits++
+	period.Added += commit.Added
+	period.Removed += commit.Removed
+}
+
+func busiestPeriod(periods map[int]*WrappedPeriod) *WrappedPeriod {
+	var busiest *WrappedPeriod
+	for _, period := range periods {
+		if busiest == nil || period.Commits > busiest.Commits ||
+			(period.Commits == busiest.Commits && period.Start < busiest.Start) {
+			busiest = period
+		}
+	}
+	return busiest
+}
+
+// weekStart returns the Monday of the week a day falls in, like the web's craziest week
+func weekStart(day int) int {
+	// Day 0 (1970-01-01) was a Thursday
+	return day - ((day+3)%7+7)%7
+}
+
+func commitSize(commit database.CommitStats) int {
+	return commit.Added + commit.Removed
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+
+	"github.com/immatheus/gitback/storage"
+	"github.com/joho/godotenv"
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, `Usage:
+  cache-tool report [-prefix cache/]
+      Count cached objects and their size by schema version
+  cache-tool purge [-prefix cache/] [-below N | -version N] [-dry-run]
+      Delete objects older than schema version N (default: the current version), or of exactly version N
+
+The cache backend is configured with the same environment variables as the server.
+Current schema version: %d
+`, storage.CACHE_SCHEMA_VERSION)
+	os.Exit(2)
+}
+
+func main() {
+	godotenv.Load()
+	log.SetFlags(0)
+
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	// The in-process tier only matters to the server
+	os.Setenv("CACHE_MEMORY_TIER_MB", "0")
+	if err := storage.Init(); err != nil {
+		log.Fatalf("Failed to initialize storage cache: %v", err)
+	}
+	defer storage.Close()
+
+	switch os.Args[1] {
+	case "report":
+		report(os.Args[2:])
+	case "purge":
+		purge(os.Args[2:])
+	default:
+		usage()
+	}
+}
+
+func report(args []string) {
+	flags := flag.NewFlagSet("report", flag.ExitOnError)
+	prefix := flags.String("prefix", "cache/", "only report keys starting with prefix")
+	flags.Parse(args)
+
+	entries, err := storage.ListCacheEntries(*prefix)
+	if err != nil {
+		log.Fatalf("Failed to list cache: %v", err)
+	}
+
+	counts := make(map[int]int)
+	sizes := make(map[int]int64)
+	for _, entry := range entries {
+		counts[entry.SchemaVersion]++
+		sizes[entry.SchemaVersion] += entry.Size
+	}
+
+	versions := make([]int, 0, len(counts))
+	for version := range counts {
+		versions = append(versions, version)
+	}
+	sort.Ints(versions)
+
+	fmt.Printf("%-8s %10s %14s\n", "VERSION", "OBJECTS", "SIZE")
+	for _, version := range versions {
+		marker := ""
+		if version == storage.CACHE_SCHEMA_VERSION {
+			marker = " (current)"
+		}
+		fmt.Printf("%-8d %10d %11.2f MB%s\n", version, counts[version], float64(sizes[version])/1024/1024, marker)
+	}
+	fmt.Printf("%d objects under %q\n", len(entries), *prefix)
+}
+
+func purge(args []string) {
+	flags := flag.NewFlagSet("purge", flag.ExitOnError)
+	prefix := flags.String("prefix", "cache/", "only purge keys starting with prefix")
+	below := flags.Int("below", storage.CACHE_SCHEMA_VERSION, "purge objects with a schema version below this")
+	version := flags.Int("version", 0, "purge objects with exactly this schema version instead")
+	dryRun := flags.Bool("dry-run", false, "list the objects that would be purged without deleting them")
+	flags.Parse(args)
+
+	entries, err := storage.ListCacheEntries(*prefix)
+	if err != nil {
+		log.Fatalf("Failed to list cache: %v", err)
+	}
+
+	purged := 0
+	for _, entry := range entries {
+		if *version != 0 && entry.SchemaVersion != *version {
+			continue
+		}
+		if *version == 0 && entry.SchemaVersion >= *below {
+			continue
+		}
+
+		if *dryRun {
+			fmt.Printf("would purge %s (version %d)\n", entry.Key, entry.SchemaVersion)
+		} else if err := storage.DeleteCacheObject(entry.Key); err != nil {
+			log.Printf("Failed to purge %s: %v", entry.Key, err)
+			continue
+		}
+		purged++
+	}
+
+	if *dryRun {
+		fmt.Printf("%d of %d objects would be purged\n", purged, len(entries))
+		return
+	}
+	fmt.Printf("Purged %d of %d objects\n", purged, len(entries))
+}
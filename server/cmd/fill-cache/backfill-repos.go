@@ -45,13 +45,13 @@ func fetchAllReposFromDB() ([]RepoInfo, error) {
 	}
 	defer database.Close()
 
-	repos, err := database.GetTopRepos()
+	page, err := database.GetTopRepos(database.TopReposQuery{})
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch repos from database: %w", err)
 	}
 
 	var repoInfos []RepoInfo
-	for _, repo := range repos {
+	for _, repo := range page.Repos {
 		repoInfos = append(repoInfos, RepoInfo{
 			Username: repo.Username,
 			Repo:     repo.RepoName,
@@ -0,0 +1,130 @@
+package columnar
+
+import (
+	"fmt"
+
+	database "github.com/immatheus/gitback/databases"
+)
+
+// Content types clients can ask for with the Accept header to get commits in columnar form
+const (
+	ContentTypeJSON    = "application/vnd.gitback.columnar+json"
+	ContentTypeMsgpack = "application/vnd.gitback.columnar+msgpack"
+)
+
+// Commits stores a list of commits as parallel arrays, one entry per commit in every array. Authors
+// are stored once and referenced by index, and dates are delta encoded, which together with the
+// compression middleware makes payloads several times smaller than a list of objects
+type Commits struct {
+	Hashes    []string `json:"h"`
+	Authors   []string `json:"authors"` // each distinct author once, in order of first appearance
+	AuthorIDs []int    `json:"a"`       // index into Authors
+	Dates     []int64  `json:"d"`       // first is unix seconds, the rest are deltas from the previous commit
+	Timezones []int    `json:"z"`
+	Added     []int    `json:"+"`
+	Removed   []int    `json:"-,"`
+	Messages  []string `json:"m"`
+	Files     []int    `json:"f"`
+}
+
+// Encode converts commits to columnar form, keeping their order
+func Encode(commits []database.CommitStats) Commits {
+	n := len(commits)
+	encoded := Commits{
+		Hashes:    make([]string, n),
+		Authors:   []string{},
+		AuthorIDs: make([]int, n),
+		Dates:     make([]int64, n),
+		Timezones: make([]int, n),
+		Added:     make([]int, n),
+		Removed:   make([]int, n),
+		Messages:  make([]string, n),
+		Files:     make([]int, n),
+	}
+
+	authorIDs := make(map[string]int)
+	var previousDate int64
+
+	for i, commit := range commits {
+		id, ok := authorIDs[commit.Author]
+		if !ok {
+			id = len(encoded.Authors)
+			authorIDs[commit.Author] = id
+			encoded.Authors = append(encoded.Authors, commit.Author)
+		}
+
+		encoded.Hashes[i] = commit.Hash
+		encoded.AuthorIDs[i] = id
+		encoded.Dates[i] = commit.Date - previousDate
+		encoded.Timezones[i] = commit.TimezoneOffset
+		encoded.Added[i] = commit.Added
+		encoded.Removed[i] = commit.Removed
+		encoded.Messages[i] = commit.Message
+		encoded.Files[i] = commit.FilesTouchedCount
+
+		previousDate = commit.Date
+	}
+
+	return encoded
+}
+
+// Decode converts columnar commits back into a list, failing if the columns are inconsistent. The columns
+// of fields the list form omits when zero (z, +, -, m and f) may be left out entirely
+func (c Commits) Decode() ([]database.CommitStats, error) {
+	n := len(c.Hashes)
+	required := map[string]int{
+		"a": len(c.AuthorIDs),
+		"d": len(c.Dates),
+	}
+	optional := map[string]int{
+		"z": len(c.Timezones),
+		"+": len(c.Added),
+		"-": len(c.Removed),
+		"m": len(c.Messages),
+		"f": len(c.Files),
+	}
+	for name, length := range required {
+		if length != n {
+			return nil, fmt.Errorf("column %q has %d entries, expected %d", name, length, n)
+		}
+	}
+	for name, length := range optional {
+		if length != n && length != 0 {
+			return nil, fmt.Errorf("column %q has %d entries, expected %d or none", name, length, n)
+		}
+	}
+
+	commits := make([]database.CommitStats, n)
+	var date int64
+
+	for i := range commits {
+		id := c.AuthorIDs[i]
+		if id < 0 || id >= len(c.Authors) {
+			return nil, fmt.Errorf("author index %d out of range at commit %d", id, i)
+		}
+
+		date += c.Dates[i]
+		commits[i] = database.CommitStats{
+			Hash:   c.Hashes[i],
+			Author: c.Authors[id],
+			Date:   date,
+		}
+		if len(c.Timezones) > 0 {
+			commits[i].TimezoneOffset = c.Timezones[i]
+		}
+		if len(c.Added) > 0 {
+			commits[i].Added = c.Added[i]
+		}
+		if len(c.Removed) > 0 {
+			commits[i].Removed = c.Removed[i]
+		}
+		if len(c.Messages) > 0 {
+			commits[i].Message = c.Messages[i]
+		}
+		if len(c.Files) > 0 {
+			commits[i].FilesTouchedCount = c.Files[i]
+		}
+	}
+
+	return commits, nil
+}
@@ -0,0 +1,127 @@
+package columnar
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"testing"
+
+	database "github.com/immatheus/gitback/databases"
+)
+
+// testCommits covers repeated authors, out of order dates, zero fields the list form omits and values
+// past int32
+func testCommits() []database.CommitStats {
+	return []database.CommitStats{
+		{Hash: "a1b2c3d", Author: "Ann", Date: 1700000000, TimezoneOffset: 120, Added: 10, Removed: 2, Message: "first", FilesTouchedCount: 3},
+		{Hash: "b2c3d4e", Author: "Bob", Date: 1700003600},
+		{Hash: "c3d4e5f", Author: "Ann", Date: 1699990000, TimezoneOffset: -300, Removed: 7},
+		{Hash: "d4e5f6a", Author: "Cy", Date: math.MaxInt32 + 1000, Added: math.MaxInt32 + 5, Removed: math.MaxInt32 + 7, FilesTouchedCount: math.MaxInt32 + 9},
+	}
+}
+
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	tests := map[string][]database.CommitStats{
+		"empty":   {},
+		"commits": testCommits(),
+	}
+
+	for name, commits := range tests {
+		t.Run(name, func(t *testing.T) {
+			decoded, err := Encode(commits).Decode()
+			if err != nil {
+				t.Fatalf("Decode failed: %v", err)
+			}
+			if !reflect.DeepEqual(decoded, commits) {
+				t.Errorf("round trip mismatch\ngot  %+v\nwant %+v", decoded, commits)
+			}
+		})
+	}
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	commits := testCommits()
+
+	// Start from the list form clients get today, where zero fields are left out
+	listJSON, err := json.Marshal(commits)
+	if err != nil {
+		t.Fatalf("failed to marshal commits: %v", err)
+	}
+	var list []database.CommitStats
+	if err := json.Unmarshal(listJSON, &list); err != nil {
+		t.Fatalf("failed to unmarshal commits: %v", err)
+	}
+
+	data, err := json.Marshal(Encode(list))
+	if err != nil {
+		t.Fatalf("failed to marshal columns: %v", err)
+	}
+	var columns Commits
+	if err := json.Unmarshal(data, &columns); err != nil {
+		t.Fatalf("failed to unmarshal columns: %v", err)
+	}
+
+	decoded, err := columns.Decode()
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if !reflect.DeepEqual(decoded, commits) {
+		t.Errorf("round trip mismatch\ngot  %+v\nwant %+v", decoded, commits)
+	}
+}
+
+func TestJSONKeys(t *testing.T) {
+	data, err := json.Marshal(Encode(testCommits()[:1]))
+	if err != nil {
+		t.Fatalf("failed to marshal columns: %v", err)
+	}
+
+	var keys map[string]json.RawMessage
+	if err := json.Unmarshal(data, &keys); err != nil {
+		t.Fatalf("failed to unmarshal columns: %v", err)
+	}
+	for _, key := range []string{"h", "authors", "a", "d", "z", "+", "-", "m", "f"} {
+		if _, ok := keys[key]; !ok {
+			t.Errorf("missing column %q in %s", key, data)
+		}
+	}
+}
+
+func TestDecodeOmittedColumns(t *testing.T) {
+	// Only the required columns, as a client leaving out all-zero columns would send
+	data := []byte(`{"h":["a","b"],"authors":["Ann"],"a":[0,0],"d":[1700000000,60]}`)
+
+	var columns Commits
+	if err := json.Unmarshal(data, &columns); err != nil {
+		t.Fatalf("failed to unmarshal columns: %v", err)
+	}
+	decoded, err := columns.Decode()
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+
+	want := []database.CommitStats{
+		{Hash: "a", Author: "Ann", Date: 1700000000},
+		{Hash: "b", Author: "Ann", Date: 1700000060},
+	}
+	if !reflect.DeepEqual(decoded, want) {
+		t.Errorf("got %+v, want %+v", decoded, want)
+	}
+}
+
+func TestDecodeInconsistentColumns(t *testing.T) {
+	tests := map[string]Commits{
+		"short required column": {Hashes: []string{"a", "b"}, Authors: []string{"Ann"}, AuthorIDs: []int{0}, Dates: []int64{1, 2}},
+		"short optional column": {Hashes: []string{"a", "b"}, Authors: []string{"Ann"}, AuthorIDs: []int{0, 0}, Dates: []int64{1, 2}, Added: []int{1}},
+		"author out of range":   {Hashes: []string{"a"}, Authors: []string{"Ann"}, AuthorIDs: []int{1}, Dates: []int64{1}},
+		"negative author":       {Hashes: []string{"a"}, Authors: []string{"Ann"}, AuthorIDs: []int{-1}, Dates: []int64{1}},
+	}
+
+	for name, columns := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := columns.Decode(); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
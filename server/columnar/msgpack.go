@@ -0,0 +1,102 @@
+package columnar
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/tinylib/msgp/msgp"
+)
+
+// MarshalMsg implements msgp.Marshaler, writing every column as a typed array
+func (c Commits) MarshalMsg(b []byte) ([]byte, error) {
+	b = msgp.AppendMapHeader(b, 9)
+
+	b = appendStrings(msgp.AppendString(b, "h"), c.Hashes)
+	b = appendStrings(msgp.AppendString(b, "authors"), c.Authors)
+	b = appendInts(msgp.AppendString(b, "a"), c.AuthorIDs)
+
+	b = msgp.AppendArrayHeader(msgp.AppendString(b, "d"), uint32(len(c.Dates)))
+	for _, date := range c.Dates {
+		b = msgp.AppendInt64(b, date)
+	}
+
+	b = appendInts(msgp.AppendString(b, "z"), c.Timezones)
+	b = appendInts(msgp.AppendString(b, "+"), c.Added)
+	b = appendInts(msgp.AppendString(b, "-"), c.Removed)
+	b = appendStrings(msgp.AppendString(b, "m"), c.Messages)
+	b = appendInts(msgp.AppendString(b, "f"), c.Files)
+
+	return b, nil
+}
+
+// MarshalMsgpack encodes v as MessagePack. Values without their own msgp encoding are written in the
+// shape of their JSON form, so keys match the JSON API
+func MarshalMsgpack(v interface{}) ([]byte, error) {
+	return appendValue(nil, v)
+}
+
+// UnmarshalMsgpack decodes MessagePack written by MarshalMsgpack into v, using v's JSON tags
+func UnmarshalMsgpack(data []byte, v interface{}) error {
+	generic, rest, err := msgp.ReadIntfBytes(data)
+	if err != nil {
+		return fmt.Errorf("failed to read msgpack: %w", err)
+	}
+	if len(rest) > 0 {
+		return fmt.Errorf("unexpected %d trailing bytes after msgpack value", len(rest))
+	}
+
+	jsonData, err := json.Marshal(generic)
+	if err != nil {
+		return fmt.Errorf("failed to convert msgpack value: %w", err)
+	}
+	return json.Unmarshal(jsonData, v)
+}
+
+func appendValue(b []byte, v interface{}) ([]byte, error) {
+	switch value := v.(type) {
+	case msgp.Marshaler:
+		return value.MarshalMsg(b)
+	case map[string]interface{}:
+		b = msgp.AppendMapHeader(b, uint32(len(value)))
+		for key, item := range value {
+			var err error
+			b = msgp.AppendString(b, key)
+			if b, err = appendValue(b, item); err != nil {
+				return b, fmt.Errorf("%s: %w", key, err)
+			}
+		}
+		return b, nil
+	}
+
+	jsonData, err := json.Marshal(v)
+	if err != nil {
+		return b, err
+	}
+
+	// Numbers stay json.Number so integers are written as msgpack ints rather than floats
+	decoder := json.NewDecoder(bytes.NewReader(jsonData))
+	decoder.UseNumber()
+
+	var generic interface{}
+	if err := decoder.Decode(&generic); err != nil {
+		return b, err
+	}
+	return msgp.AppendIntf(b, generic)
+}
+
+func appendStrings(b []byte, values []string) []byte {
+	b = msgp.AppendArrayHeader(b, uint32(len(values)))
+	for _, value := range values {
+		b = msgp.AppendString(b, value)
+	}
+	return b
+}
+
+func appendInts(b []byte, values []int) []byte {
+	b = msgp.AppendArrayHeader(b, uint32(len(values)))
+	for _, value := range values {
+		b = msgp.AppendInt(b, value)
+	}
+	return b
+}
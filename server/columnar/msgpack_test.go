@@ -0,0 +1,104 @@
+package columnar
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	database "github.com/immatheus/gitback/databases"
+)
+
+func TestMsgpackRoundTrip(t *testing.T) {
+	tests := map[string][]database.CommitStats{
+		"empty":   {},
+		"commits": testCommits(),
+	}
+
+	for name, commits := range tests {
+		t.Run(name, func(t *testing.T) {
+			data, err := MarshalMsgpack(Encode(commits))
+			if err != nil {
+				t.Fatalf("MarshalMsgpack failed: %v", err)
+			}
+
+			var columns Commits
+			if err := UnmarshalMsgpack(data, &columns); err != nil {
+				t.Fatalf("UnmarshalMsgpack failed: %v", err)
+			}
+			decoded, err := columns.Decode()
+			if err != nil {
+				t.Fatalf("Decode failed: %v", err)
+			}
+			if !reflect.DeepEqual(decoded, commits) {
+				t.Errorf("round trip mismatch\ngot  %+v\nwant %+v", decoded, commits)
+			}
+		})
+	}
+}
+
+// TestMsgpackResponse decodes a response the way a client does, with the analysis fields around the
+// columnar commits written from their JSON form
+func TestMsgpackResponse(t *testing.T) {
+	commits := testCommits()
+	body := map[string]interface{}{
+		"totalCommits": len(commits),
+		"totalAdded":   int64(math.MaxInt32) * 3,
+		"github":       nil,
+		"commits":      Encode(commits),
+		"activity": struct {
+			Weekdays []int `json:"weekdays"`
+		}{Weekdays: []int{1, 2, 3}},
+	}
+
+	data, err := MarshalMsgpack(body)
+	if err != nil {
+		t.Fatalf("MarshalMsgpack failed: %v", err)
+	}
+
+	var response struct {
+		TotalCommits int                      `json:"totalCommits"`
+		TotalAdded   int64                    `json:"totalAdded"`
+		GitHub       *string                  `json:"github"`
+		Commits      Commits                  `json:"commits"`
+		Activity     struct{ Weekdays []int } `json:"activity"`
+	}
+	if err := UnmarshalMsgpack(data, &response); err != nil {
+		t.Fatalf("UnmarshalMsgpack failed: %v", err)
+	}
+
+	if response.TotalCommits != len(commits) {
+		t.Errorf("totalCommits = %d, want %d", response.TotalCommits, len(commits))
+	}
+	if response.TotalAdded != int64(math.MaxInt32)*3 {
+		t.Errorf("totalAdded = %d, want %d", response.TotalAdded, int64(math.MaxInt32)*3)
+	}
+	if response.GitHub != nil {
+		t.Errorf("github = %v, want nil", *response.GitHub)
+	}
+	if !reflect.DeepEqual(response.Activity.Weekdays, []int{1, 2, 3}) {
+		t.Errorf("activity.weekdays = %v, want [1 2 3]", response.Activity.Weekdays)
+	}
+
+	decoded, err := response.Commits.Decode()
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if !reflect.DeepEqual(decoded, commits) {
+		t.Errorf("commits mismatch\ngot  %+v\nwant %+v", decoded, commits)
+	}
+}
+
+func TestUnmarshalMsgpackTrailingBytes(t *testing.T) {
+	data, err := MarshalMsgpack(Encode(testCommits()))
+	if err != nil {
+		t.Fatalf("MarshalMsgpack failed: %v", err)
+	}
+
+	var columns Commits
+	if err := UnmarshalMsgpack(append(data, 0xc0), &columns); err == nil {
+		t.Error("expected an error for trailing bytes")
+	}
+	if err := UnmarshalMsgpack(data[:len(data)/2], &columns); err == nil {
+		t.Error("expected an error for truncated input")
+	}
+}
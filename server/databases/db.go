@@ -1,50 +1,88 @@
 package database
 
 import (
-	"database/sql"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
 	"sort"
+	"strings"
 	"time"
-
-	_ "github.com/lib/pq"
 )
 
-var db *sql.DB
+// Repository stores analyzed repositories
+type Repository interface {
+	// SaveRepo creates or updates a repository's row
+	SaveRepo(data RepoData) error
+	// IncrementViews counts a view in the lifetime total and today's daily views
+	IncrementViews(username, repoName string) error
+	// GetDailyViews returns the daily view counts of every visible repo since the given day
+	GetDailyViews(since time.Time) ([]DailyViews, error)
+	// GetRepo returns nil when the repository hasn't been analyzed
+	GetRepo(username, repoName string) (*RepoData, error)
+	// GetTopRepos returns a page of the leaderboard and how many repos match the query across all pages
+	GetTopRepos(query TopReposQuery) (RepoPage, error)
+	// SearchRepos returns the page after the given cursor of the repos whose owner/repo matches term, nil
+	// for the first page, and how many match in total
+	SearchRepos(term string, limit int, after Cursor) (RepoPage, error)
+	UpdateLastCachedAt(username, repoName string) error
+	// DeleteRepo removes a repository's row, reporting false when there was none
+	DeleteRepo(username, repoName string) (bool, error)
+	// SetRepoHidden hides a repository from the top repos or shows it again, reporting false when there
+	// is no such repository
+	SetRepoHidden(username, repoName string, hidden bool) (bool, error)
+	ListExclusions() ([]Exclusion, error)
+	// AddExclusion leaves repos out of the top repos, replacing the reason of an existing exclusion
+	AddExclusion(exclusion Exclusion) error
+	// RemoveExclusion reports false when there was no such exclusion
+	RemoveExclusion(username, repoName string) (bool, error)
+	// ReplaceCommits swaps the stored commit history of a saved repository for commits
+	ReplaceCommits(username, repoName string, commits []CommitStats) error
+	// GetAuthorCommits returns the most recent MaxAuthorCommits stored commits by identity across visible
+	// repos, oldest first
+	GetAuthorCommits(identity string) ([]AuthorCommit, error)
+	// SaveSnapshot records the metrics of one analysis of a saved repository
+	SaveSnapshot(username, repoName string, snapshot RepoSnapshot) error
+	// GetSnapshots returns a repository's snapshots taken since the given time, oldest first
+	GetSnapshots(username, repoName string, since time.Time) ([]RepoSnapshot, error)
+	Close() error
+}
+
+var (
+	repository Repository
+	schema     *migrator
+)
 
+// Init connects to the database in dsn. sqlite:path opens a SQLite file, anything else is a Postgres
+// connection string
 func Init(dsn string) error {
-	var err error
-	db, err = sql.Open("postgres", dsn)
-	if err != nil {
-		return fmt.Errorf("failed to open database: %w", err)
+	if path, ok := sqlitePath(dsn); ok {
+		sqlite, err := NewSQLiteRepository(path)
+		if err != nil {
+			return err
+		}
+		repository, schema = sqlite, sqlite.migrator()
+		return nil
 	}
 
-	if err := db.Ping(); err != nil {
-		return fmt.Errorf("failed to ping database: %w", err)
+	postgres, err := NewPostgresRepository(dsn)
+	if err != nil {
+		return err
 	}
+	repository, schema = postgres, postgres.migrator()
+	return nil
+}
 
-	db.SetMaxOpenConns(10)
-	db.SetMaxIdleConns(5)
-	db.SetConnMaxLifetime(time.Hour)
-
-	log.Printf("Database connection established")
-
-	go func() {
-		_, err := db.Exec(`
-			ALTER TABLE repos 
-			ADD COLUMN IF NOT EXISTS last_cached_at timestamp;
-		`)
-		if err != nil {
-			log.Printf("Failed to run migration for 'last_cached_at' column: %v", err)
+func sqlitePath(dsn string) (string, bool) {
+	for _, prefix := range []string{"sqlite://", "sqlite:"} {
+		if strings.HasPrefix(dsn, prefix) {
+			return strings.TrimPrefix(dsn, prefix), true
 		}
-	}()
-	return nil
+	}
+	return "", false
 }
 
 func Close() error {
-	if db != nil {
-		return db.Close()
+	if repository != nil {
+		return repository.Close()
 	}
 	return nil
 }
@@ -64,11 +102,83 @@ type RepoData struct {
 	LastCachedAt   *time.Time `json:"lastCachedAt,omitempty"`
 }
 
+// Sorts accepted by TopReposQuery
+const (
+	TopReposByLines   = "lines"
+	TopReposByStars   = "stars"
+	TopReposByCommits = "commits"
+	TopReposByViews   = "views"
+	TopReposByRecent  = "recent" // most recently analyzed first
+)
+
+const DefaultTopReposLimit = 100
+
+// TopReposQuery filters, sorts and pages the top repos. The zero value is the first 100 repos by lines
+type TopReposQuery struct {
+	Sort     string
+	Language string // matched case insensitively
+	MinStars int
+	Limit    int
+	After    Cursor // Next of the previous page, nil for the first page
+}
+
+// Cursor is the sort key of the last repo of a page, the next page starts after it. Its values are what
+// the driver read, so they compare in the database the way the rows were ordered
+type Cursor []interface{}
+
+// ErrInvalidCursor is returned for a cursor that doesn't fit the query's sort
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// RepoPage is one page of repos
+type RepoPage struct {
+	Repos []RepoData
+	Total int    // repos matching the query across all pages
+	Next  Cursor // nil on the last page
+}
+
+// Exclusion leaves a repo out of the top repos. An empty Username matches RepoName under any owner
+type Exclusion struct {
+	Username  string    `json:"username"`
+	RepoName  string    `json:"repoName"`
+	Reason    string    `json:"reason"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// DailyViews is how many times a repo was viewed on one UTC day
+type DailyViews struct {
+	Username string
+	RepoName string
+	Day      time.Time
+	Views    int
+}
+
+// AuthorCommit is a stored commit along with the repository it belongs to
+type AuthorCommit struct {
+	Username string
+	RepoName string
+	CommitStats
+}
+
+// RepoSnapshot is a repository's metrics as of one analysis
+type RepoSnapshot struct {
+	AnalyzedAt        time.Time `json:"analyzedAt"`
+	HeadSHA           string    `json:"headSha"`
+	TotalCommits      int       `json:"totalCommits"`
+	TotalAdditions    int       `json:"totalAdditions"`
+	TotalRemovals     int       `json:"totalRemovals"`
+	TotalLines        int       `json:"totalLines"`
+	TotalContributors int       `json:"totalContributors"`
+	TotalStars        int       `json:"totalStars"`
+	Language          string    `json:"language"`
+}
+
 // we do this weird json names to minify the payload size, its small but it matters at scale
 type CommitStats struct {
 	Hash              string `json:"h"`
 	Author            string `json:"a"`
+	Email             string `json:"-"` // only persisted to the commits table, never sent to clients
 	Date              int64  `json:"d"`
+	TimezoneOffset    int    `json:"z,omitempty"` // author's UTC offset in minutes
 	Added             int    `json:"+,omitempty"`
 	Removed           int    `json:"-,omitempty"`
 	Message           string `json:"m,omitempty"`
@@ -76,185 +186,138 @@ type CommitStats struct {
 }
 
 func SaveRepo(data RepoData) error {
-	if db == nil {
+	if repository == nil {
 		return fmt.Errorf("database not initialized")
 	}
-
-	histogramJSON, err := json.Marshal(data.LinesHistogram)
-	if err != nil {
-		return fmt.Errorf("failed to marshal histogram: %w", err)
-	}
-
-	// PostgreSQL upsert using ON CONFLICT
-	query := `
-		INSERT INTO repos (
-			username, 
-			repo_name, 
-			total_additions, 
-			total_lines, 
-			total_removals, 
-			views, 
-			lines_histogram,
-			total_stars,
-			total_commits,
-			language,
-			size_kb,
-			last_cached_at,
-			updated_at
-		) VALUES ($1, $2, $3, $4, $5, 0, $6, $7, $8, $9, $10, $11, NOW())
-		ON CONFLICT (username, repo_name) 
-		DO UPDATE SET
-			total_additions = EXCLUDED.total_additions,
-			total_lines = EXCLUDED.total_lines,
-			total_removals = EXCLUDED.total_removals,
-			lines_histogram = EXCLUDED.lines_histogram,
-			total_stars = EXCLUDED.total_stars,
-			total_commits = EXCLUDED.total_commits,
-			language = EXCLUDED.language,
-			size_kb = EXCLUDED.size_kb,
-			last_cached_at = EXCLUDED.last_cached_at,
-			updated_at = NOW()
-	`
-
-	_, err = db.Exec(
-		query,
-		data.Username,
-		data.RepoName,
-		data.TotalAdditions,
-		data.TotalLines,
-		data.TotalRemovals,
-		string(histogramJSON),
-		data.TotalStars,
-		data.TotalCommits,
-		data.Language,
-		data.Size,
-		data.LastCachedAt,
-	)
-
-	if err != nil {
-		return fmt.Errorf("failed to save repo: %w", err)
-	}
-
-	log.Printf("Saved repo data for %s/%s to database", data.Username, data.RepoName)
-	return nil
+	return repository.SaveRepo(data)
 }
 
+// IncrementViews counts a view in the lifetime total and today's daily views
 func IncrementViews(username, repoName string) error {
-	if db == nil {
+	if repository == nil {
 		return fmt.Errorf("database not initialized")
 	}
+	return repository.IncrementViews(username, repoName)
+}
 
-	query := `
-		UPDATE repos 
-		SET views = views + 1 
-		WHERE username = $1 AND repo_name = $2
-	`
+// GetDailyViews returns the daily view counts of every visible repo since the given day
+func GetDailyViews(since time.Time) ([]DailyViews, error) {
+	if repository == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+	return repository.GetDailyViews(since)
+}
 
-	result, err := db.Exec(query, username, repoName)
-	if err != nil {
-		return fmt.Errorf("failed to increment views: %w", err)
+func GetRepo(username, repoName string) (*RepoData, error) {
+	if repository == nil {
+		return nil, fmt.Errorf("database not initialized")
 	}
+	return repository.GetRepo(username, repoName)
+}
 
-	rows, _ := result.RowsAffected()
-	if rows == 0 {
-		log.Printf("No repo found to increment views: %s/%s", username, repoName)
+// GetTopRepos returns a page of the leaderboard and how many repos match the query across all pages
+func GetTopRepos(query TopReposQuery) (RepoPage, error) {
+	if repository == nil {
+		return RepoPage{}, fmt.Errorf("database not initialized")
 	}
+	return repository.GetTopRepos(query)
+}
 
-	return nil
+// SearchRepos returns the page after the given cursor of the repos whose owner/repo matches term, nil
+// for the first page, and how many match in total
+func SearchRepos(term string, limit int, after Cursor) (RepoPage, error) {
+	if repository == nil {
+		return RepoPage{}, fmt.Errorf("database not initialized")
+	}
+	return repository.SearchRepos(term, limit, after)
 }
 
-func GetRepo(username, repoName string) (*RepoData, error) {
-	if db == nil {
+func ListExclusions() ([]Exclusion, error) {
+	if repository == nil {
 		return nil, fmt.Errorf("database not initialized")
 	}
+	return repository.ListExclusions()
+}
 
-	query := `
-		SELECT username, repo_name, total_additions, total_lines, total_removals, lines_histogram
-		FROM repos
-		WHERE username = $1 AND repo_name = $2
-	`
-
-	var data RepoData
-	var histogramJSON string
-
-	err := db.QueryRow(query, username, repoName).Scan(
-		&data.Username,
-		&data.RepoName,
-		&data.TotalAdditions,
-		&data.TotalLines,
-		&data.TotalRemovals,
-		&histogramJSON,
-	)
-
-	if err == sql.ErrNoRows {
-		return nil, nil // Not found
-	}
-	if err != nil {
-		return nil, fmt.Errorf("failed to get repo: %w", err)
+// AddExclusion leaves repos out of the top repos, replacing the reason of an existing exclusion
+func AddExclusion(exclusion Exclusion) error {
+	if repository == nil {
+		return fmt.Errorf("database not initialized")
 	}
+	return repository.AddExclusion(exclusion)
+}
 
-	// Parse histogram JSON
-	if err := json.Unmarshal([]byte(histogramJSON), &data.LinesHistogram); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal histogram: %w", err)
+// RemoveExclusion reports false when there was no such exclusion
+func RemoveExclusion(username, repoName string) (bool, error) {
+	if repository == nil {
+		return false, fmt.Errorf("database not initialized")
 	}
+	return repository.RemoveExclusion(username, repoName)
+}
 
-	return &data, nil
+// DeleteRepo removes a repository's row, reporting false when there was none
+func DeleteRepo(username, repoName string) (bool, error) {
+	if repository == nil {
+		return false, fmt.Errorf("database not initialized")
+	}
+	return repository.DeleteRepo(username, repoName)
 }
 
-func GetTopRepos() ([]RepoData, error) {
-	if db == nil {
-		return nil, fmt.Errorf("database not initialized")
+// SetRepoHidden hides a repository from the top repos or shows it again, reporting false when there
+// is no such repository
+func SetRepoHidden(username, repoName string, hidden bool) (bool, error) {
+	if repository == nil {
+		return false, fmt.Errorf("database not initialized")
 	}
+	return repository.SetRepoHidden(username, repoName, hidden)
+}
 
-	query := `
-		SELECT username, repo_name, total_additions, total_lines, total_removals, views, lines_histogram, total_stars, total_commits
-		FROM repos
-		WHERE repo_name != 'linux'
-		AND total_lines > 0
-		AND total_commits > 1
-		ORDER BY total_lines DESC
-		LIMIT 100
-	`
-
-	rows, err := db.Query(query)
-	if err != nil {
-		return nil, fmt.Errorf("failed to query top repos: %w", err)
+func UpdateLastCachedAt(username, repoName string) error {
+	if repository == nil {
+		return fmt.Errorf("database not initialized")
 	}
-	defer rows.Close()
-
-	var repos []RepoData
-	for rows.Next() {
-		var data RepoData
-		var histogramJSON string
-
-		err := rows.Scan(
-			&data.Username,
-			&data.RepoName,
-			&data.TotalAdditions,
-			&data.TotalLines,
-			&data.TotalRemovals,
-			&data.Views,
-			&histogramJSON,
-			&data.TotalStars,
-			&data.TotalCommits,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan repo row: %w", err)
-		}
+	return repository.UpdateLastCachedAt(username, repoName)
+}
 
-		// Parse histogram JSON
-		if err := json.Unmarshal([]byte(histogramJSON), &data.LinesHistogram); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal histogram: %w", err)
-		}
+// ReplaceCommits swaps the stored commit history of a saved repository for commits
+func ReplaceCommits(username, repoName string, commits []CommitStats) error {
+	if repository == nil {
+		return fmt.Errorf("database not initialized")
+	}
+	return repository.ReplaceCommits(username, repoName, commits)
+}
 
-		repos = append(repos, data)
+// SaveSnapshot records the metrics of one analysis of a saved repository
+func SaveSnapshot(username, repoName string, snapshot RepoSnapshot) error {
+	if repository == nil {
+		return fmt.Errorf("database not initialized")
 	}
+	return repository.SaveSnapshot(username, repoName, snapshot)
+}
 
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating rows: %w", err)
+// GetSnapshots returns a repository's snapshots taken since the given time, oldest first
+func GetSnapshots(username, repoName string, since time.Time) ([]RepoSnapshot, error) {
+	if repository == nil {
+		return nil, fmt.Errorf("database not initialized")
 	}
+	return repository.GetSnapshots(username, repoName, since)
+}
+
+// GitHubNoreplyDomain is the domain of the addresses GitHub commits with when the author's email is private,
+// handle@ or id+handle@ it
+const GitHubNoreplyDomain = "users.noreply.github.com"
+
+// MaxAuthorCommits caps the commits GetAuthorCommits loads, so prolific authors don't pull in whole histories
+const MaxAuthorCommits = 50000
 
-	return repos, nil
+// GetAuthorCommits returns the most recent MaxAuthorCommits stored commits by identity across visible repos,
+// oldest first. An identity with an @ is matched against commit emails, anything else against author names
+// and GitHub handles
+func GetAuthorCommits(identity string) ([]AuthorCommit, error) {
+	if repository == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+	return repository.GetAuthorCommits(identity)
 }
 
 func CalculateLinesHistogram(commits []CommitStats, points int) []int {
@@ -296,28 +359,25 @@ func CalculateLinesHistogram(commits []CommitStats, points int) []int {
 	return histogram
 }
 
-func UpdateLastCachedAt(username, repoName string) error {
-	if db == nil {
-		return fmt.Errorf("database not initialized")
-	}
-
-	query := `
-		UPDATE repos 
-		SET last_cached_at = NOW() 
-		WHERE username = $1 AND repo_name = $2
-	`
-
-	result, err := db.Exec(query, username, repoName)
-	if err != nil {
-		return fmt.Errorf("failed to update last cached timestamp: %w", err)
-	}
+// CalculateLinesAt returns the lines of code as of each of the given ascending unix timestamps, so curves
+// of different repos line up by calendar time where CalculateLinesHistogram splits by commit count
+func CalculateLinesAt(commits []CommitStats, times []int64) []int {
+	sortedCommits := make([]CommitStats, len(commits))
+	copy(sortedCommits, commits)
+	sort.Slice(sortedCommits, func(i, j int) bool {
+		return sortedCommits[i].Date < sortedCommits[j].Date
+	})
 
-	rows, _ := result.RowsAffected()
-	if rows == 0 {
-		log.Printf("No repo found to update cache timestamp: %s/%s", username, repoName)
-	} else {
-		log.Printf("Updated cache timestamp for %s/%s", username, repoName)
+	lines := make([]int, len(times))
+	totalLines := 0
+	next := 0
+	for i, at := range times {
+		for next < len(sortedCommits) && sortedCommits[next].Date <= at {
+			totalLines += sortedCommits[next].Added - sortedCommits[next].Removed
+			next++
+		}
+		lines[i] = totalLines
 	}
 
-	return nil
+	return lines
 }
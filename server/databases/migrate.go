@@ -0,0 +1,273 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+//go:embed migrations/postgres/*.sql migrations/sqlite/*.sql
+var migrationFiles embed.FS
+
+// migrationLockID keys the Postgres advisory lock that stops two instances from migrating at once
+const migrationLockID = 727274
+
+var migrationName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// migrator applies one backend's migrations
+type migrator struct {
+	db    *sql.DB
+	files fs.FS
+	dir   string
+	// advisoryLock takes a Postgres advisory lock so two instances starting together don't both migrate
+	advisoryLock bool
+}
+
+// Migration is a numbered schema change with the SQL to apply and revert it
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationState is a known migration and when it was applied, nil when it is pending
+type MigrationState struct {
+	Version   int        `json:"version"`
+	Name      string     `json:"name"`
+	AppliedAt *time.Time `json:"appliedAt,omitempty"`
+}
+
+// loadMigrations reads the embedded NNN_name.up.sql and NNN_name.down.sql files ordered by version
+func loadMigrations(files fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(files, dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		match := migrationName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("migration %s is not named NNN_name.up.sql or NNN_name.down.sql", entry.Name())
+		}
+
+		version, _ := strconv.Atoi(match[1])
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		} else if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has two names, %s and %s", version, migration.Name, match[2])
+		}
+
+		sql, err := fs.ReadFile(files, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", entry.Name(), err)
+		}
+		if match[3] == "up" {
+			migration.Up = string(sql)
+		} else {
+			migration.Down = string(sql)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up file", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// MigrateUp applies every pending migration in order, each in its own transaction
+func MigrateUp() error {
+	if schema == nil {
+		return fmt.Errorf("database not initialized")
+	}
+	return schema.withLock(func(ctx context.Context, conn *sql.Conn) error {
+		migrations, applied, err := schema.plan(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		pending := 0
+		for _, migration := range migrations {
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+
+			start := time.Now()
+			err := inTransaction(ctx, conn, func(tx *sql.Tx) error {
+				if _, err := tx.ExecContext(ctx, migration.Up); err != nil {
+					return err
+				}
+				_, err := tx.ExecContext(ctx,
+					`INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`,
+					migration.Version, migration.Name,
+				)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("failed to apply migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+
+			log.Printf("[DB] Applied migration %d_%s (took %v)", migration.Version, migration.Name, time.Since(start))
+			pending++
+		}
+
+		if pending == 0 {
+			log.Printf("[DB] Schema is up to date")
+		}
+		return nil
+	})
+}
+
+// MigrateDown reverts the last steps applied migrations, newest first
+func MigrateDown(steps int) error {
+	if schema == nil {
+		return fmt.Errorf("database not initialized")
+	}
+	return schema.withLock(func(ctx context.Context, conn *sql.Conn) error {
+		migrations, applied, err := schema.plan(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(migrations) - 1; i >= 0 && steps > 0; i-- {
+			migration := migrations[i]
+			if _, ok := applied[migration.Version]; !ok {
+				continue
+			}
+			if migration.Down == "" {
+				return fmt.Errorf("migration %d_%s has no down file", migration.Version, migration.Name)
+			}
+
+			err := inTransaction(ctx, conn, func(tx *sql.Tx) error {
+				if _, err := tx.ExecContext(ctx, migration.Down); err != nil {
+					return err
+				}
+				_, err := tx.ExecContext(ctx, `DELETE FROM schema_migrations WHERE version = $1`, migration.Version)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("failed to revert migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+
+			log.Printf("[DB] Reverted migration %d_%s", migration.Version, migration.Name)
+			steps--
+		}
+		return nil
+	})
+}
+
+// MigrationStatus lists every known migration and whether it has been applied
+func MigrationStatus() ([]MigrationState, error) {
+	if schema == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+
+	var states []MigrationState
+	err := schema.withLock(func(ctx context.Context, conn *sql.Conn) error {
+		migrations, applied, err := schema.plan(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range migrations {
+			state := MigrationState{Version: migration.Version, Name: migration.Name}
+			if appliedAt, ok := applied[migration.Version]; ok {
+				state.AppliedAt = &appliedAt
+			}
+			states = append(states, state)
+		}
+		return nil
+	})
+	return states, err
+}
+
+// withLock runs fn on a single connection, holding the migration advisory lock on Postgres
+func (m *migrator) withLock(fn func(ctx context.Context, conn *sql.Conn) error) error {
+	ctx := context.Background()
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get connection: %w", err)
+	}
+	defer conn.Close()
+
+	if m.advisoryLock {
+		// Advisory locks belong to the session, so the lock and unlock have to use the same connection
+		if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, migrationLockID); err != nil {
+			return fmt.Errorf("failed to take migration lock: %w", err)
+		}
+		defer conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1)`, migrationLockID)
+	}
+
+	return fn(ctx, conn)
+}
+
+// plan creates schema_migrations if needed and returns the known migrations and the applied ones
+func (m *migrator) plan(ctx context.Context, conn *sql.Conn) ([]Migration, map[int]time.Time, error) {
+	migrations, err := loadMigrations(m.files, m.dir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	_, err = conn.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	rows, err := conn.QueryContext(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, nil, fmt.Errorf("failed to scan schema_migrations row: %w", err)
+		}
+		applied[version] = appliedAt
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return migrations, applied, nil
+}
+
+func inTransaction(ctx context.Context, conn *sql.Conn, fn func(tx *sql.Tx) error) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,220 @@
+package database
+
+import (
+	"io/fs"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// newTestSQLite opens an in-memory SQLite database as the package's repository and schema
+func newTestSQLite(t *testing.T) *SQLiteRepository {
+	t.Helper()
+
+	sqlite, err := NewSQLiteRepository(":memory:")
+	if err != nil {
+		t.Fatalf("NewSQLiteRepository failed: %v", err)
+	}
+
+	previousRepository, previousSchema := repository, schema
+	repository, schema = sqlite, sqlite.migrator()
+	t.Cleanup(func() {
+		repository, schema = previousRepository, previousSchema
+		sqlite.Close()
+	})
+
+	return sqlite
+}
+
+func tableExists(t *testing.T, sqlite *SQLiteRepository, name string) bool {
+	t.Helper()
+
+	var count int
+	err := sqlite.db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE name = $1`, name).Scan(&count)
+	if err != nil {
+		t.Fatalf("failed to look up %s: %v", name, err)
+	}
+	return count > 0
+}
+
+// appliedVersions returns the status as version numbers split by whether they are applied
+func appliedVersions(t *testing.T) (applied, pending []int) {
+	t.Helper()
+
+	states, err := MigrationStatus()
+	if err != nil {
+		t.Fatalf("MigrationStatus failed: %v", err)
+	}
+	for _, state := range states {
+		if state.AppliedAt != nil {
+			applied = append(applied, state.Version)
+		} else {
+			pending = append(pending, state.Version)
+		}
+	}
+	return applied, pending
+}
+
+func TestMigrateUpIsIdempotent(t *testing.T) {
+	sqlite := newTestSQLite(t)
+
+	if err := MigrateUp(); err != nil {
+		t.Fatalf("first MigrateUp failed: %v", err)
+	}
+	if err := MigrateUp(); err != nil {
+		t.Fatalf("second MigrateUp failed: %v", err)
+	}
+
+	var recorded int
+	if err := sqlite.db.QueryRow(`SELECT COUNT(*) FROM schema_migrations`).Scan(&recorded); err != nil {
+		t.Fatalf("failed to count schema_migrations: %v", err)
+	}
+	migrations, err := loadMigrations(migrationFiles, "migrations/sqlite")
+	if err != nil {
+		t.Fatalf("loadMigrations failed: %v", err)
+	}
+	if recorded != len(migrations) {
+		t.Errorf("schema_migrations has %d rows, want %d", recorded, len(migrations))
+	}
+}
+
+func TestMigrationStatus(t *testing.T) {
+	newTestSQLite(t)
+
+	applied, pending := appliedVersions(t)
+	if len(applied) != 0 || len(pending) == 0 {
+		t.Fatalf("before migrating: applied %v, pending %v, want everything pending", applied, pending)
+	}
+
+	if err := MigrateUp(); err != nil {
+		t.Fatalf("MigrateUp failed: %v", err)
+	}
+
+	states, err := MigrationStatus()
+	if err != nil {
+		t.Fatalf("MigrationStatus failed: %v", err)
+	}
+	if len(states) != len(pending) {
+		t.Fatalf("MigrationStatus listed %d migrations, want %d", len(states), len(pending))
+	}
+	for i, state := range states {
+		if state.AppliedAt == nil {
+			t.Errorf("migration %d_%s is not applied", state.Version, state.Name)
+		}
+		if state.Name == "" {
+			t.Errorf("migration %d has no name", state.Version)
+		}
+		if i > 0 && state.Version <= states[i-1].Version {
+			t.Errorf("migrations out of order: %d after %d", state.Version, states[i-1].Version)
+		}
+	}
+}
+
+func TestMigrateDownRevertsNewestFirst(t *testing.T) {
+	sqlite := newTestSQLite(t)
+
+	if err := MigrateUp(); err != nil {
+		t.Fatalf("MigrateUp failed: %v", err)
+	}
+	all, _ := appliedVersions(t)
+
+	if err := MigrateDown(1); err != nil {
+		t.Fatalf("MigrateDown(1) failed: %v", err)
+	}
+	applied, pending := appliedVersions(t)
+	if len(pending) != 1 || pending[0] != all[len(all)-1] {
+		t.Fatalf("after one step down pending = %v, want only %d", pending, all[len(all)-1])
+	}
+	if tableExists(t, sqlite, "idx_commits_author_handle") {
+		t.Error("idx_commits_author_handle still exists after reverting its migration")
+	}
+	if !tableExists(t, sqlite, "idx_commits_author_name") {
+		t.Error("idx_commits_author_name was dropped by reverting a later migration")
+	}
+
+	if err := MigrateDown(3); err != nil {
+		t.Fatalf("MigrateDown(3) failed: %v", err)
+	}
+	applied, pending = appliedVersions(t)
+	if want := all[:len(all)-4]; len(applied) != len(want) || applied[len(applied)-1] != want[len(want)-1] {
+		t.Errorf("after four steps down applied = %v, want %v", applied, want)
+	}
+	if tableExists(t, sqlite, "repo_views_daily") || tableExists(t, sqlite, "excluded_repos") {
+		t.Error("tables of reverted migrations still exist")
+	}
+	if !tableExists(t, sqlite, "repo_snapshots") {
+		t.Error("repo_snapshots was dropped, down went further than asked")
+	}
+
+	// Reverted migrations apply again
+	if err := MigrateUp(); err != nil {
+		t.Fatalf("MigrateUp after MigrateDown failed: %v", err)
+	}
+	if _, pending := appliedVersions(t); len(pending) != 0 {
+		t.Errorf("pending after migrating up again: %v", pending)
+	}
+}
+
+func TestMigrateUpFailureRollsBack(t *testing.T) {
+	sqlite := newTestSQLite(t)
+	schema.files = fstest.MapFS{
+		"test/001_create_things.up.sql":   {Data: []byte(`CREATE TABLE things (id INTEGER PRIMARY KEY);`)},
+		"test/001_create_things.down.sql": {Data: []byte(`DROP TABLE things;`)},
+		"test/002_broken.up.sql": {Data: []byte(`
+			CREATE TABLE others (id INTEGER PRIMARY KEY);
+			INSERT INTO things (id) VALUES (1);
+			INSERT INTO missing (id) VALUES (1);
+		`)},
+	}
+	schema.dir = "test"
+
+	err := MigrateUp()
+	if err == nil || !strings.Contains(err.Error(), "2_broken") {
+		t.Fatalf("MigrateUp returned %v, want an error naming 2_broken", err)
+	}
+
+	applied, pending := appliedVersions(t)
+	if len(applied) != 1 || applied[0] != 1 || len(pending) != 1 || pending[0] != 2 {
+		t.Errorf("applied %v, pending %v, want 1 applied and 2 pending", applied, pending)
+	}
+	if tableExists(t, sqlite, "others") {
+		t.Error("table created by the failed migration was kept")
+	}
+
+	var things int
+	if err := sqlite.db.QueryRow(`SELECT COUNT(*) FROM things`).Scan(&things); err != nil {
+		t.Fatalf("failed to count things: %v", err)
+	}
+	if things != 0 {
+		t.Errorf("things has %d rows, the failed migration's insert was kept", things)
+	}
+}
+
+func TestLoadMigrations(t *testing.T) {
+	for _, dir := range []string{"migrations/postgres", "migrations/sqlite"} {
+		migrations, err := loadMigrations(migrationFiles, dir)
+		if err != nil {
+			t.Fatalf("loadMigrations(%s) failed: %v", dir, err)
+		}
+		for i, migration := range migrations {
+			if migration.Version != i+1 {
+				t.Errorf("%s: migration %d_%s, want version %d", dir, migration.Version, migration.Name, i+1)
+			}
+			if migration.Down == "" {
+				t.Errorf("%s: migration %d_%s has no down file", dir, migration.Version, migration.Name)
+			}
+		}
+	}
+
+	invalid := map[string]fs.FS{
+		"bad name":     fstest.MapFS{"m/001-create.up.sql": {Data: []byte("SELECT 1")}},
+		"two names":    fstest.MapFS{"m/001_a.up.sql": {Data: []byte("SELECT 1")}, "m/001_b.down.sql": {Data: []byte("SELECT 1")}},
+		"no up":        fstest.MapFS{"m/001_a.down.sql": {Data: []byte("SELECT 1")}},
+		"no directory": fstest.MapFS{},
+	}
+	for name, files := range invalid {
+		if _, err := loadMigrations(files, "m"); err == nil {
+			t.Errorf("%s: loadMigrations succeeded", name)
+		}
+	}
+}
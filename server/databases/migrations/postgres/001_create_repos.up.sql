@@ -1,4 +1,4 @@
-CREATE TABLE repos (
+CREATE TABLE IF NOT EXISTS repos (
     id SERIAL PRIMARY KEY,
     username VARCHAR(255) NOT NULL,
     repo_name VARCHAR(255) NOT NULL,
@@ -16,6 +16,6 @@ CREATE TABLE repos (
     CONSTRAINT unique_repo UNIQUE (username, repo_name)
 );
 
-CREATE INDEX idx_username_repo_name ON repos(username, repo_name);
-CREATE INDEX idx_views ON repos(views);
-CREATE INDEX idx_updated_at ON repos(updated_at);
\ No newline at end of file
+CREATE INDEX IF NOT EXISTS idx_username_repo_name ON repos(username, repo_name);
+CREATE INDEX IF NOT EXISTS idx_views ON repos(views);
+CREATE INDEX IF NOT EXISTS idx_updated_at ON repos(updated_at);
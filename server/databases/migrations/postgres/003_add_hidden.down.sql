@@ -0,0 +1 @@
+ALTER TABLE repos DROP COLUMN IF EXISTS hidden;
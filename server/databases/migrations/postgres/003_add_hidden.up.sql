@@ -0,0 +1 @@
+ALTER TABLE repos ADD COLUMN IF NOT EXISTS hidden BOOLEAN NOT NULL DEFAULT false;
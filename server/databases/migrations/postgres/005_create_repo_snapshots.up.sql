@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS repo_snapshots (
+    id BIGSERIAL PRIMARY KEY,
+    repo_id INTEGER NOT NULL REFERENCES repos(id) ON DELETE CASCADE,
+    analyzed_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    head_sha VARCHAR(40) NOT NULL DEFAULT '',
+    total_commits INTEGER NOT NULL DEFAULT 0,
+    total_additions INTEGER NOT NULL DEFAULT 0,
+    total_removals INTEGER NOT NULL DEFAULT 0,
+    total_lines INTEGER NOT NULL DEFAULT 0,
+    total_contributors INTEGER NOT NULL DEFAULT 0,
+    total_stars INTEGER NOT NULL DEFAULT 0,
+    language VARCHAR(100) NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS idx_repo_snapshots_repo_analyzed_at ON repo_snapshots(repo_id, analyzed_at);
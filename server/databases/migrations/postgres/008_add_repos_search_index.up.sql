@@ -0,0 +1,18 @@
+-- pg_trgm needs permission to create the extension and isn't shipped everywhere, without it repo search
+-- falls back to a plain ILIKE match
+DO $$
+BEGIN
+    CREATE EXTENSION IF NOT EXISTS pg_trgm;
+EXCEPTION WHEN insufficient_privilege OR undefined_file OR feature_not_supported THEN
+    RAISE NOTICE 'pg_trgm is unavailable, skipping the repo search index: %', SQLERRM;
+END
+$$;
+
+-- Serves both the ILIKE substring match and the % similarity match of repo search
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM pg_extension WHERE extname = 'pg_trgm') THEN
+        CREATE INDEX IF NOT EXISTS idx_repos_full_name_trgm ON repos USING gin ((username || '/' || repo_name) gin_trgm_ops);
+    END IF;
+END
+$$;
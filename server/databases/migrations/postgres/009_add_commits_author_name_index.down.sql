@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_commits_author_name;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_commits_author_handle;
+ALTER TABLE commits DROP COLUMN IF EXISTS author_handle;
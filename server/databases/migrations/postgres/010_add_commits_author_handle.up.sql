@@ -0,0 +1,9 @@
+-- The GitHub handle of noreply commit emails, lowercased, so author lookups by handle can use an index
+-- instead of a leading wildcard LIKE over every email
+ALTER TABLE commits ADD COLUMN IF NOT EXISTS author_handle TEXT NOT NULL DEFAULT '';
+
+UPDATE commits
+SET author_handle = substring(LOWER(author_email) from '^(?:[0-9]+\+)?([^@]+)@users\.noreply\.github\.com$')
+WHERE LOWER(author_email) LIKE '%@users.noreply.github.com';
+
+CREATE INDEX IF NOT EXISTS idx_commits_author_handle ON commits(author_handle);
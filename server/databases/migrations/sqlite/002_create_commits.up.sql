@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS commits (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    repo_id INTEGER NOT NULL REFERENCES repos(id) ON DELETE CASCADE,
+    hash TEXT NOT NULL,
+    author_name TEXT NOT NULL,
+    author_email TEXT NOT NULL DEFAULT '',
+    committed_at TIMESTAMP NOT NULL,
+    timezone_offset INTEGER NOT NULL DEFAULT 0,
+    added INTEGER NOT NULL DEFAULT 0,
+    removed INTEGER NOT NULL DEFAULT 0,
+    files INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_commits_repo_hash ON commits(repo_id, hash);
+CREATE INDEX IF NOT EXISTS idx_commits_repo_committed_at ON commits(repo_id, committed_at);
+CREATE INDEX IF NOT EXISTS idx_commits_author_email ON commits(author_email);
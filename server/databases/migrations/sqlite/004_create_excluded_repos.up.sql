@@ -0,0 +1,13 @@
+-- Repos left out of the top repos, an empty username matches the repo name under any owner
+CREATE TABLE IF NOT EXISTS excluded_repos (
+    username TEXT NOT NULL DEFAULT '',
+    repo_name TEXT NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (username, repo_name)
+);
+
+-- Used to be hardcoded in the top repos query, its line count dwarfs everything else
+INSERT INTO excluded_repos (username, repo_name, reason)
+VALUES ('', 'linux', 'Outlier that dwarfs every other repo')
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS repo_views_daily (
+    repo_id INTEGER NOT NULL REFERENCES repos(id) ON DELETE CASCADE,
+    day DATE NOT NULL,
+    views INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (repo_id, day)
+);
+
+CREATE INDEX IF NOT EXISTS idx_repo_views_daily_day ON repo_views_daily(day);
@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS idx_commits_author_name ON commits(LOWER(author_name));
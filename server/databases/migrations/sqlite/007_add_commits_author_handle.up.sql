@@ -0,0 +1,19 @@
+-- The GitHub handle of noreply commit emails, lowercased, so author lookups by handle can use an index
+-- instead of a leading wildcard LIKE over every email
+ALTER TABLE commits ADD COLUMN author_handle TEXT NOT NULL DEFAULT '';
+
+-- id+handle@ or handle@, SQLite has no regexp so the id is whatever digits come before the first +
+UPDATE commits
+SET author_handle = LOWER(CASE
+    WHEN instr(local_part, '+') > 1 AND substr(local_part, 1, instr(local_part, '+') - 1) NOT GLOB '*[^0-9]*'
+        THEN substr(local_part, instr(local_part, '+') + 1)
+    ELSE local_part
+END)
+FROM (
+    SELECT id AS commit_id, substr(author_email, 1, instr(author_email, '@') - 1) AS local_part
+    FROM commits
+    WHERE author_email LIKE '%@users.noreply.github.com'
+) noreply
+WHERE commits.id = noreply.commit_id;
+
+CREATE INDEX IF NOT EXISTS idx_commits_author_handle ON commits(author_handle);
@@ -0,0 +1,126 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// PostgresRepository stores repositories in Postgres
+type PostgresRepository struct {
+	sqlRepository
+	// noTrigram is set once a search finds pg_trgm missing, later searches go straight to ILIKE
+	noTrigram atomic.Bool
+}
+
+// NewPostgresRepository connects to the Postgres database in dsn
+func NewPostgresRepository(dsn string) (*PostgresRepository, error) {
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	if err := db.Ping(); err != nil {
+		return nil, fmt.Errorf("failed to ping database: %w", err)
+	}
+
+	db.SetMaxOpenConns(10)
+	db.SetMaxIdleConns(5)
+	db.SetConnMaxLifetime(time.Hour)
+
+	log.Printf("Database connection established")
+
+	return &PostgresRepository{sqlRepository: sqlRepository{db: db}}, nil
+}
+
+func (p *PostgresRepository) migrator() *migrator {
+	return &migrator{db: p.db, files: migrationFiles, dir: "migrations/postgres", advisoryLock: true}
+}
+
+// ReplaceCommits streams commits in with COPY, which is far faster than inserts for repos with
+// hundreds of thousands of commits
+func (p *PostgresRepository) ReplaceCommits(username, repoName string, commits []CommitStats) error {
+	start := time.Now()
+
+	tx, err := p.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	repoID, err := clearCommits(tx, username, repoName, true)
+	if err != nil {
+		return err
+	}
+
+	stmt, err := tx.Prepare(pq.CopyIn("commits", commitColumns...))
+	if err != nil {
+		return fmt.Errorf("failed to start copy: %w", err)
+	}
+
+	for _, commit := range commits {
+		if _, err := stmt.Exec(commitValues(repoID, commit)...); err != nil {
+			stmt.Close()
+			return fmt.Errorf("failed to copy commit: %w", err)
+		}
+	}
+
+	// An Exec without arguments flushes the buffered rows
+	if _, err := stmt.Exec(); err != nil {
+		stmt.Close()
+		return fmt.Errorf("failed to copy commits: %w", err)
+	}
+	if err := stmt.Close(); err != nil {
+		return fmt.Errorf("failed to copy commits: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	log.Printf("Saved %d commits for %s/%s (took %v)", len(commits), username, repoName, time.Since(start))
+	return nil
+}
+
+// undefinedFunction is the error code Postgres returns for the % operator and similarity() without pg_trgm
+const undefinedFunction = "42883"
+
+// SearchRepos matches term as a substring of owner/repo or, through pg_trgm, as a near match for typos.
+// Exact and prefix matches on the repo name rank first, then the closest matches. Without pg_trgm it
+// falls back to the substring match alone
+func (p *PostgresRepository) SearchRepos(term string, limit int, after Cursor) (RepoPage, error) {
+	if p.noTrigram.Load() {
+		return p.sqlRepository.SearchRepos(term, limit, after)
+	}
+
+	term = strings.ToLower(term)
+	order := []sortKey{
+		{"LOWER(repo_name) = $2", true},
+		{`LOWER(repo_name) LIKE $3 ESCAPE '\'`, true},
+		{"similarity(username || '/' || repo_name, $2)", true},
+		{"views", true},
+		byID,
+	}
+
+	prefix := strings.TrimPrefix(likePattern(term), "%")
+	page, err := queryRepoPage(p.db, `NOT hidden
+		AND ((username || '/' || repo_name) ILIKE $1 ESCAPE '\' OR (username || '/' || repo_name) % $2)`,
+		[]interface{}{likePattern(term), term, prefix}, order, limit, after)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == undefinedFunction {
+			log.Printf("[DB] pg_trgm is not installed, repo search falls back to ILIKE")
+			p.noTrigram.Store(true)
+			// The fallback orders by fewer keys, it turns down a cursor from a trigram search as invalid
+			return p.sqlRepository.SearchRepos(term, limit, after)
+		}
+		return RepoPage{}, fmt.Errorf("failed to search repos: %w", err)
+	}
+	return page, nil
+}
@@ -0,0 +1,692 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// sqlRepository holds the queries Postgres and SQLite share, both accept $N placeholders
+type sqlRepository struct {
+	db *sql.DB
+}
+
+// commitColumns are the commits table columns written for each commit, in commitValues order
+var commitColumns = []string{
+	"repo_id", "hash", "author_name", "author_email", "author_handle", "committed_at", "timezone_offset", "added",
+	"removed", "files",
+}
+
+// noreplyEmail matches GitHub noreply addresses, the handle is the first group
+var noreplyEmail = regexp.MustCompile(`(?i)^(?:\d+\+)?([^@]+)@` + regexp.QuoteMeta(GitHubNoreplyDomain) + `$`)
+
+// noreplyHandle returns the lowercased GitHub handle of a noreply email, empty for any other email
+func noreplyHandle(email string) string {
+	if m := noreplyEmail.FindStringSubmatch(email); m != nil {
+		return strings.ToLower(m[1])
+	}
+	return ""
+}
+
+func commitValues(repoID int64, commit CommitStats) []interface{} {
+	return []interface{}{
+		repoID,
+		commit.Hash,
+		commit.Author,
+		commit.Email,
+		noreplyHandle(commit.Email),
+		time.Unix(commit.Date, 0).UTC(),
+		commit.TimezoneOffset,
+		commit.Added,
+		commit.Removed,
+		commit.FilesTouchedCount,
+	}
+}
+
+// clearCommits looks up a repository's id and deletes its stored commits within tx. forUpdate locks the
+// repo row until tx ends, so a concurrent replace waits and then deletes the rows this one inserted
+func clearCommits(tx *sql.Tx, username, repoName string, forUpdate bool) (int64, error) {
+	query := `SELECT id FROM repos WHERE username = $1 AND repo_name = $2`
+	if forUpdate {
+		query += ` FOR UPDATE`
+	}
+
+	var repoID int64
+	err := tx.QueryRow(query, username, repoName).Scan(&repoID)
+	if err == sql.ErrNoRows {
+		return 0, fmt.Errorf("repo %s/%s not found", username, repoName)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get repo id: %w", err)
+	}
+
+	if _, err := tx.Exec(`DELETE FROM commits WHERE repo_id = $1`, repoID); err != nil {
+		return 0, fmt.Errorf("failed to delete commits: %w", err)
+	}
+	return repoID, nil
+}
+
+func (s *sqlRepository) Close() error {
+	return s.db.Close()
+}
+
+func (s *sqlRepository) SaveRepo(data RepoData) error {
+	histogramJSON, err := json.Marshal(data.LinesHistogram)
+	if err != nil {
+		return fmt.Errorf("failed to marshal histogram: %w", err)
+	}
+
+	// PostgreSQL upsert using ON CONFLICT
+	query := `
+		INSERT INTO repos (
+			username, 
+			repo_name, 
+			total_additions, 
+			total_lines, 
+			total_removals, 
+			views, 
+			lines_histogram,
+			total_stars,
+			total_commits,
+			language,
+			size_kb,
+			last_cached_at,
+			updated_at
+		) VALUES ($1, $2, $3, $4, $5, 0, $6, $7, $8, $9, $10, $11, CURRENT_TIMESTAMP)
+		ON CONFLICT (username, repo_name) 
+		DO UPDATE SET
+			total_additions = EXCLUDED.total_additions,
+			total_lines = EXCLUDED.total_lines,
+			total_removals = EXCLUDED.total_removals,
+			lines_histogram = EXCLUDED.lines_histogram,
+			total_stars = EXCLUDED.total_stars,
+			total_commits = EXCLUDED.total_commits,
+			language = EXCLUDED.language,
+			size_kb = EXCLUDED.size_kb,
+			last_cached_at = EXCLUDED.last_cached_at,
+			updated_at = CURRENT_TIMESTAMP
+	`
+
+	_, err = s.db.Exec(
+		query,
+		data.Username,
+		data.RepoName,
+		data.TotalAdditions,
+		data.TotalLines,
+		data.TotalRemovals,
+		string(histogramJSON),
+		data.TotalStars,
+		data.TotalCommits,
+		data.Language,
+		data.Size,
+		data.LastCachedAt,
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to save repo: %w", err)
+	}
+
+	log.Printf("Saved repo data for %s/%s to database", data.Username, data.RepoName)
+	return nil
+}
+
+func (s *sqlRepository) IncrementViews(username, repoName string) error {
+	query := `
+		UPDATE repos 
+		SET views = views + 1 
+		WHERE username = $1 AND repo_name = $2
+	`
+
+	result, err := s.db.Exec(query, username, repoName)
+	if err != nil {
+		return fmt.Errorf("failed to increment views: %w", err)
+	}
+
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		log.Printf("No repo found to increment views: %s/%s", username, repoName)
+		return nil
+	}
+
+	// The WHERE keeps SQLite from reading ON CONFLICT as part of the SELECT
+	daily := `
+		INSERT INTO repo_views_daily (repo_id, day, views)
+		SELECT id, $3, 1
+		FROM repos
+		WHERE username = $1 AND repo_name = $2
+		ON CONFLICT (repo_id, day) DO UPDATE SET views = repo_views_daily.views + 1
+	`
+
+	if _, err := s.db.Exec(daily, username, repoName, time.Now().UTC().Format("2006-01-02")); err != nil {
+		return fmt.Errorf("failed to increment daily views: %w", err)
+	}
+
+	return nil
+}
+
+func (s *sqlRepository) GetDailyViews(since time.Time) ([]DailyViews, error) {
+	query := `
+		SELECT r.username, r.repo_name, v.day, v.views
+		FROM repo_views_daily v
+		JOIN repos r ON r.id = v.repo_id
+		WHERE v.day >= $1 AND NOT r.hidden
+		ORDER BY v.day
+	`
+
+	rows, err := s.db.Query(query, since.UTC().Format("2006-01-02"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query daily views: %w", err)
+	}
+	defer rows.Close()
+
+	var views []DailyViews
+	for rows.Next() {
+		var day DailyViews
+		if err := rows.Scan(&day.Username, &day.RepoName, &day.Day, &day.Views); err != nil {
+			return nil, fmt.Errorf("failed to scan daily views row: %w", err)
+		}
+		views = append(views, day)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return views, nil
+}
+
+func (s *sqlRepository) GetRepo(username, repoName string) (*RepoData, error) {
+	query := `
+		SELECT username, repo_name, total_additions, total_lines, total_removals, lines_histogram
+		FROM repos
+		WHERE username = $1 AND repo_name = $2
+	`
+
+	var data RepoData
+	var histogramJSON string
+
+	err := s.db.QueryRow(query, username, repoName).Scan(
+		&data.Username,
+		&data.RepoName,
+		&data.TotalAdditions,
+		&data.TotalLines,
+		&data.TotalRemovals,
+		&histogramJSON,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, nil // Not found
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get repo: %w", err)
+	}
+
+	// Parse histogram JSON
+	if err := json.Unmarshal([]byte(histogramJSON), &data.LinesHistogram); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal histogram: %w", err)
+	}
+
+	return &data, nil
+}
+
+// sortKey is one ORDER BY term of a page of repos
+type sortKey struct {
+	expr string
+	desc bool
+}
+
+// byID ends every order, ids are unique so no two repos tie and pages neither skip nor repeat any
+var byID = sortKey{expr: "id"}
+
+// topReposOrder maps each TopReposQuery sort to its ORDER BY
+var topReposOrder = map[string][]sortKey{
+	TopReposByLines:   {{"total_lines", true}, byID},
+	TopReposByStars:   {{"total_stars", true}, byID},
+	TopReposByCommits: {{"total_commits", true}, byID},
+	TopReposByViews:   {{"views", true}, byID},
+	TopReposByRecent:  {{"COALESCE(last_cached_at, updated_at)", true}, byID},
+}
+
+func (s *sqlRepository) GetTopRepos(query TopReposQuery) (RepoPage, error) {
+	order, ok := topReposOrder[query.Sort]
+	if query.Sort == "" {
+		order, ok = topReposOrder[TopReposByLines], true
+	}
+	if !ok {
+		return RepoPage{}, fmt.Errorf("unknown sort %q", query.Sort)
+	}
+	if query.Limit <= 0 {
+		query.Limit = DefaultTopReposLimit
+	}
+
+	conditions := []string{
+		"NOT hidden",
+		"total_lines > 0",
+		"total_commits > 1",
+		`NOT EXISTS (
+			SELECT 1 FROM excluded_repos e
+			WHERE e.repo_name = repos.repo_name AND (e.username = '' OR e.username = repos.username)
+		)`,
+	}
+	var args []interface{}
+
+	if query.Language != "" {
+		args = append(args, query.Language)
+		conditions = append(conditions, fmt.Sprintf("LOWER(language) = LOWER($%d)", len(args)))
+	}
+	if query.MinStars > 0 {
+		args = append(args, query.MinStars)
+		conditions = append(conditions, fmt.Sprintf("total_stars >= $%d", len(args)))
+	}
+
+	page, err := queryRepoPage(s.db, strings.Join(conditions, "\n\t\tAND "), args, order, query.Limit, query.After)
+	if err != nil {
+		return RepoPage{}, fmt.Errorf("failed to query top repos: %w", err)
+	}
+	return page, nil
+}
+
+// repoPageColumns are the columns scanRepoPage reads, the window count is the total across all pages
+const repoPageColumns = `username, repo_name, total_additions, total_lines, total_removals, views, lines_histogram,
+			total_stars, total_commits, language, last_cached_at, COUNT(*) OVER ()`
+
+// queryRepoPage reads the page of repos matching filter that comes after the after cursor in the given
+// order. Each sort key is selected as k0, k1, ... in a subquery, so the cursor compares against exactly
+// what the rows were ordered by and the window count still covers every match
+func queryRepoPage(db *sql.DB, filter string, args []interface{}, order []sortKey, limit int, after Cursor) (RepoPage, error) {
+	if after != nil && len(after) != len(order) {
+		return RepoPage{}, ErrInvalidCursor
+	}
+
+	selected := make([]string, len(order))
+	orderBy := make([]string, len(order))
+	for i, key := range order {
+		selected[i] = fmt.Sprintf("%s AS k%d", key.expr, i)
+		orderBy[i] = fmt.Sprintf("k%d", i)
+		if key.desc {
+			orderBy[i] += " DESC"
+		}
+	}
+
+	filterArgs := len(args)
+	resume := ""
+	if after != nil {
+		// Rows after the cursor: equal on the first keys and past it on the next one
+		alternatives := make([]string, len(order))
+		for i, key := range order {
+			terms := make([]string, 0, i+1)
+			for j := 0; j < i; j++ {
+				terms = append(terms, fmt.Sprintf("k%d = $%d", j, filterArgs+j+1))
+			}
+			op := ">"
+			if key.desc {
+				op = "<"
+			}
+			terms = append(terms, fmt.Sprintf("k%d %s $%d", i, op, filterArgs+i+1))
+			alternatives[i] = "(" + strings.Join(terms, " AND ") + ")"
+		}
+		resume = "WHERE " + strings.Join(alternatives, "\n\t\tOR ")
+		args = append(args, after...)
+	}
+
+	// One more row than asked tells whether there is a next page
+	args = append(args, limit+1)
+	query := fmt.Sprintf(`
+		SELECT * FROM (
+			SELECT %s, %s
+			FROM repos
+			WHERE %s
+		) matching
+		%s
+		ORDER BY %s
+		LIMIT $%d
+	`, repoPageColumns, strings.Join(selected, ", "), filter, resume, strings.Join(orderBy, ", "), len(args))
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return RepoPage{}, err
+	}
+	defer rows.Close()
+
+	page, keys, err := scanRepoPage(rows, len(order))
+	if err != nil {
+		return RepoPage{}, err
+	}
+
+	if len(page.Repos) > limit {
+		page.Repos = page.Repos[:limit]
+		page.Next = keys[limit-1]
+	}
+
+	// Past the last page there are no rows to carry the count
+	if len(page.Repos) == 0 && after != nil {
+		if err := db.QueryRow(fmt.Sprintf(`SELECT COUNT(*) FROM repos WHERE %s`, filter),
+			args[:filterArgs]...).Scan(&page.Total); err != nil {
+			return RepoPage{}, fmt.Errorf("failed to count repos: %w", err)
+		}
+	}
+
+	return page, nil
+}
+
+// scanRepoPage reads rows selected with repoPageColumns followed by keyCount sort keys, returning the page
+// and the sort key of each repo
+func scanRepoPage(rows *sql.Rows, keyCount int) (RepoPage, []Cursor, error) {
+	page := RepoPage{Repos: []RepoData{}}
+	var keys []Cursor
+	for rows.Next() {
+		var data RepoData
+		var histogramJSON string
+		key := make(Cursor, keyCount)
+
+		dest := []interface{}{
+			&data.Username,
+			&data.RepoName,
+			&data.TotalAdditions,
+			&data.TotalLines,
+			&data.TotalRemovals,
+			&data.Views,
+			&histogramJSON,
+			&data.TotalStars,
+			&data.TotalCommits,
+			&data.Language,
+			&data.LastCachedAt,
+			&page.Total,
+		}
+		for i := range key {
+			dest = append(dest, &key[i])
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return RepoPage{}, nil, fmt.Errorf("failed to scan repo row: %w", err)
+		}
+
+		// Parse histogram JSON
+		if err := json.Unmarshal([]byte(histogramJSON), &data.LinesHistogram); err != nil {
+			return RepoPage{}, nil, fmt.Errorf("failed to unmarshal histogram: %w", err)
+		}
+
+		page.Repos = append(page.Repos, data)
+		keys = append(keys, key)
+	}
+
+	if err := rows.Err(); err != nil {
+		return RepoPage{}, nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return page, keys, nil
+}
+
+// escapeLike escapes the LIKE wildcards in term, for patterns with ESCAPE '\'
+func escapeLike(term string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(term)
+}
+
+// likePattern escapes LIKE wildcards in term and wraps it for a substring match
+func likePattern(term string) string {
+	return "%" + escapeLike(term) + "%"
+}
+
+// SearchRepos matches term as a substring of owner/repo, repos whose name starts with it first. Backends
+// with a better index override it
+func (s *sqlRepository) SearchRepos(term string, limit int, after Cursor) (RepoPage, error) {
+	term = strings.ToLower(term)
+	order := []sortKey{
+		{"LOWER(repo_name) = $2", true},
+		{"LOWER(repo_name) LIKE $3 ESCAPE '\\'", true},
+		{"views", true},
+		byID,
+	}
+
+	prefix := strings.TrimPrefix(likePattern(term), "%")
+	page, err := queryRepoPage(s.db, `NOT hidden AND LOWER(username || '/' || repo_name) LIKE $1 ESCAPE '\'`,
+		[]interface{}{likePattern(term), term, prefix}, order, limit, after)
+	if err != nil {
+		return RepoPage{}, fmt.Errorf("failed to search repos: %w", err)
+	}
+	return page, nil
+}
+
+func (s *sqlRepository) ListExclusions() ([]Exclusion, error) {
+	rows, err := s.db.Query(`SELECT username, repo_name, reason, created_at FROM excluded_repos ORDER BY repo_name, username`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query exclusions: %w", err)
+	}
+	defer rows.Close()
+
+	exclusions := []Exclusion{}
+	for rows.Next() {
+		var exclusion Exclusion
+		if err := rows.Scan(&exclusion.Username, &exclusion.RepoName, &exclusion.Reason, &exclusion.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan exclusion row: %w", err)
+		}
+		exclusions = append(exclusions, exclusion)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return exclusions, nil
+}
+
+func (s *sqlRepository) AddExclusion(exclusion Exclusion) error {
+	query := `
+		INSERT INTO excluded_repos (username, repo_name, reason)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (username, repo_name) DO UPDATE SET reason = EXCLUDED.reason
+	`
+
+	if _, err := s.db.Exec(query, exclusion.Username, exclusion.RepoName, exclusion.Reason); err != nil {
+		return fmt.Errorf("failed to add exclusion: %w", err)
+	}
+	return nil
+}
+
+func (s *sqlRepository) RemoveExclusion(username, repoName string) (bool, error) {
+	result, err := s.db.Exec(`DELETE FROM excluded_repos WHERE username = $1 AND repo_name = $2`, username, repoName)
+	if err != nil {
+		return false, fmt.Errorf("failed to remove exclusion: %w", err)
+	}
+
+	rows, _ := result.RowsAffected()
+	return rows > 0, nil
+}
+
+func (s *sqlRepository) DeleteRepo(username, repoName string) (bool, error) {
+	result, err := s.db.Exec(`DELETE FROM repos WHERE username = $1 AND repo_name = $2`, username, repoName)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete repo: %w", err)
+	}
+
+	rows, _ := result.RowsAffected()
+	return rows > 0, nil
+}
+
+func (s *sqlRepository) SetRepoHidden(username, repoName string, hidden bool) (bool, error) {
+	query := `
+		UPDATE repos
+		SET hidden = $3
+		WHERE username = $1 AND repo_name = $2
+	`
+
+	result, err := s.db.Exec(query, username, repoName, hidden)
+	if err != nil {
+		return false, fmt.Errorf("failed to update hidden flag: %w", err)
+	}
+
+	rows, _ := result.RowsAffected()
+	return rows > 0, nil
+}
+
+func (s *sqlRepository) UpdateLastCachedAt(username, repoName string) error {
+	query := `
+		UPDATE repos 
+		SET last_cached_at = CURRENT_TIMESTAMP 
+		WHERE username = $1 AND repo_name = $2
+	`
+
+	result, err := s.db.Exec(query, username, repoName)
+	if err != nil {
+		return fmt.Errorf("failed to update last cached timestamp: %w", err)
+	}
+
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		log.Printf("No repo found to update cache timestamp: %s/%s", username, repoName)
+	} else {
+		log.Printf("Updated cache timestamp for %s/%s", username, repoName)
+	}
+
+	return nil
+}
+
+func (s *sqlRepository) SaveSnapshot(username, repoName string, snapshot RepoSnapshot) error {
+	query := `
+		INSERT INTO repo_snapshots (
+			repo_id,
+			analyzed_at,
+			head_sha,
+			total_commits,
+			total_additions,
+			total_removals,
+			total_lines,
+			total_contributors,
+			total_stars,
+			language
+		)
+		SELECT id, $3, $4, $5, $6, $7, $8, $9, $10, $11
+		FROM repos
+		WHERE username = $1 AND repo_name = $2
+	`
+
+	result, err := s.db.Exec(
+		query,
+		username,
+		repoName,
+		snapshot.AnalyzedAt.UTC(),
+		snapshot.HeadSHA,
+		snapshot.TotalCommits,
+		snapshot.TotalAdditions,
+		snapshot.TotalRemovals,
+		snapshot.TotalLines,
+		snapshot.TotalContributors,
+		snapshot.TotalStars,
+		snapshot.Language,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save snapshot: %w", err)
+	}
+
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return fmt.Errorf("repo %s/%s not found", username, repoName)
+	}
+	return nil
+}
+
+func (s *sqlRepository) GetSnapshots(username, repoName string, since time.Time) ([]RepoSnapshot, error) {
+	query := `
+		SELECT s.analyzed_at, s.head_sha, s.total_commits, s.total_additions, s.total_removals,
+			s.total_lines, s.total_contributors, s.total_stars, s.language
+		FROM repo_snapshots s
+		JOIN repos r ON r.id = s.repo_id
+		WHERE r.username = $1 AND r.repo_name = $2 AND s.analyzed_at >= $3
+		ORDER BY s.analyzed_at
+	`
+
+	rows, err := s.db.Query(query, username, repoName, since.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to query snapshots: %w", err)
+	}
+	defer rows.Close()
+
+	snapshots := []RepoSnapshot{}
+	for rows.Next() {
+		var snapshot RepoSnapshot
+		err := rows.Scan(
+			&snapshot.AnalyzedAt,
+			&snapshot.HeadSHA,
+			&snapshot.TotalCommits,
+			&snapshot.TotalAdditions,
+			&snapshot.TotalRemovals,
+			&snapshot.TotalLines,
+			&snapshot.TotalContributors,
+			&snapshot.TotalStars,
+			&snapshot.Language,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan snapshot row: %w", err)
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return snapshots, nil
+}
+
+func (s *sqlRepository) GetAuthorCommits(identity string) ([]AuthorCommit, error) {
+	identity = strings.ToLower(identity)
+
+	match := "c.author_email = $1"
+	if !strings.Contains(identity, "@") {
+		// GitHub's noreply address is the closest thing a commit has to a username, its handle is stored
+		// apart so this stays on indexes
+		match = "(LOWER(c.author_name) = $1 OR c.author_handle = $1)"
+	}
+	args := []interface{}{identity, MaxAuthorCommits}
+
+	query := fmt.Sprintf(`
+		SELECT username, repo_name, hash, author_name, committed_at, timezone_offset, added, removed, files
+		FROM (
+			SELECT r.username, r.repo_name, c.hash, c.author_name, c.committed_at, c.timezone_offset,
+				c.added, c.removed, c.files
+			FROM commits c
+			JOIN repos r ON r.id = c.repo_id
+			WHERE %s AND NOT r.hidden
+			ORDER BY c.committed_at DESC
+			LIMIT $%d
+		) recent
+		ORDER BY committed_at
+	`, match, len(args))
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query author commits: %w", err)
+	}
+	defer rows.Close()
+
+	commits := []AuthorCommit{}
+	for rows.Next() {
+		var commit AuthorCommit
+		var committedAt time.Time
+		err := rows.Scan(
+			&commit.Username,
+			&commit.RepoName,
+			&commit.Hash,
+			&commit.Author,
+			&committedAt,
+			&commit.TimezoneOffset,
+			&commit.Added,
+			&commit.Removed,
+			&commit.FilesTouchedCount,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan author commit row: %w", err)
+		}
+		commit.Date = committedAt.Unix()
+		commits = append(commits, commit)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return commits, nil
+}
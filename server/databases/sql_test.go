@@ -0,0 +1,526 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// newMigratedSQLite opens an in-memory SQLite database with every migration applied
+func newMigratedSQLite(t *testing.T) *SQLiteRepository {
+	t.Helper()
+
+	sqlite := newTestSQLite(t)
+	if err := MigrateUp(); err != nil {
+		t.Fatalf("MigrateUp failed: %v", err)
+	}
+	return sqlite
+}
+
+func saveRepos(t *testing.T, sqlite *SQLiteRepository, repos ...RepoData) {
+	t.Helper()
+
+	for _, repo := range repos {
+		if repo.LinesHistogram == nil {
+			repo.LinesHistogram = []int{}
+		}
+		if err := sqlite.SaveRepo(repo); err != nil {
+			t.Fatalf("SaveRepo(%s/%s) failed: %v", repo.Username, repo.RepoName, err)
+		}
+	}
+}
+
+func repoNames(repos []RepoData) []string {
+	names := []string{}
+	for _, repo := range repos {
+		names = append(names, repo.Username+"/"+repo.RepoName)
+	}
+	return names
+}
+
+func TestGetTopRepos(t *testing.T) {
+	sqlite := newMigratedSQLite(t)
+
+	saveRepos(t, sqlite,
+		RepoData{Username: "a", RepoName: "big", TotalLines: 900, TotalStars: 10, TotalCommits: 50, Language: "Go",
+			LinesHistogram: []int{1, 2, 3}},
+		RepoData{Username: "b", RepoName: "starred", TotalLines: 500, TotalStars: 900, TotalCommits: 20, Language: "Rust"},
+		RepoData{Username: "c", RepoName: "busy", TotalLines: 100, TotalStars: 50, TotalCommits: 999, Language: "go"},
+		// Left out: a single commit, no lines, hidden and excluded
+		RepoData{Username: "d", RepoName: "single", TotalLines: 5000, TotalStars: 1, TotalCommits: 1, Language: "Go"},
+		RepoData{Username: "d", RepoName: "empty", TotalLines: 0, TotalStars: 1, TotalCommits: 10, Language: "Go"},
+		RepoData{Username: "e", RepoName: "hidden", TotalLines: 800, TotalStars: 1, TotalCommits: 10, Language: "Go"},
+		RepoData{Username: "f", RepoName: "excluded", TotalLines: 700, TotalStars: 1, TotalCommits: 10, Language: "Go"},
+		RepoData{Username: "g", RepoName: "awesome", TotalLines: 600, TotalStars: 1, TotalCommits: 10, Language: "Go"},
+		RepoData{Username: "h", RepoName: "awesome", TotalLines: 400, TotalStars: 1, TotalCommits: 10, Language: "Go"},
+	)
+	if _, err := sqlite.SetRepoHidden("e", "hidden", true); err != nil {
+		t.Fatalf("SetRepoHidden failed: %v", err)
+	}
+	for _, exclusion := range []Exclusion{{Username: "f", RepoName: "excluded"}, {RepoName: "awesome"}} {
+		if err := sqlite.AddExclusion(exclusion); err != nil {
+			t.Fatalf("AddExclusion failed: %v", err)
+		}
+	}
+	for i := 0; i < 3; i++ {
+		if err := sqlite.IncrementViews("c", "busy"); err != nil {
+			t.Fatalf("IncrementViews failed: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name      string
+		query     TopReposQuery
+		want      []string
+		wantTotal int
+	}{
+		{"default sort", TopReposQuery{}, []string{"a/big", "b/starred", "c/busy"}, 3},
+		{"stars", TopReposQuery{Sort: TopReposByStars}, []string{"b/starred", "c/busy", "a/big"}, 3},
+		{"commits", TopReposQuery{Sort: TopReposByCommits}, []string{"c/busy", "a/big", "b/starred"}, 3},
+		{"views", TopReposQuery{Sort: TopReposByViews}, []string{"c/busy", "a/big", "b/starred"}, 3},
+		{"language ignores case", TopReposQuery{Language: "GO"}, []string{"a/big", "c/busy"}, 2},
+		{"min stars", TopReposQuery{MinStars: 50}, []string{"b/starred", "c/busy"}, 2},
+		{"no matches", TopReposQuery{Language: "COBOL"}, []string{}, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			page, err := sqlite.GetTopRepos(test.query)
+			if err != nil {
+				t.Fatalf("GetTopRepos failed: %v", err)
+			}
+			if got := repoNames(page.Repos); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("GetTopRepos returned %v, want %v", got, test.want)
+			}
+			if page.Total != test.wantTotal || page.Next != nil {
+				t.Errorf("total = %d, next = %v, want %d and no next page", page.Total, page.Next, test.wantTotal)
+			}
+		})
+	}
+
+	page, err := sqlite.GetTopRepos(TopReposQuery{Limit: 1})
+	if err != nil {
+		t.Fatalf("GetTopRepos failed: %v", err)
+	}
+	if !reflect.DeepEqual(page.Repos[0].LinesHistogram, []int{1, 2, 3}) || page.Repos[0].Language != "Go" {
+		t.Errorf("a/big read back as %+v", page.Repos[0])
+	}
+
+	if _, err := sqlite.GetTopRepos(TopReposQuery{Sort: "nonsense"}); err == nil {
+		t.Error("GetTopRepos accepted an unknown sort")
+	}
+	if _, err := sqlite.GetTopRepos(TopReposQuery{After: Cursor{int64(1)}}); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("GetTopRepos with a cursor of the wrong length returned %v, want ErrInvalidCursor", err)
+	}
+}
+
+// TestGetTopReposPages walks the pages of every sort, with ties and repos saved between pages
+func TestGetTopReposPages(t *testing.T) {
+	sqlite := newMigratedSQLite(t)
+
+	saveRepos(t, sqlite,
+		RepoData{Username: "a", RepoName: "one", TotalLines: 300, TotalStars: 5, TotalCommits: 10},
+		RepoData{Username: "a", RepoName: "two", TotalLines: 200, TotalStars: 5, TotalCommits: 10},
+		RepoData{Username: "a", RepoName: "three", TotalLines: 200, TotalStars: 5, TotalCommits: 10},
+		RepoData{Username: "a", RepoName: "four", TotalLines: 100, TotalStars: 5, TotalCommits: 10},
+	)
+
+	for _, sort := range []string{TopReposByLines, TopReposByStars, TopReposByCommits, TopReposByViews, TopReposByRecent} {
+		t.Run(sort, func(t *testing.T) {
+			all, err := sqlite.GetTopRepos(TopReposQuery{Sort: sort})
+			if err != nil {
+				t.Fatalf("GetTopRepos failed: %v", err)
+			}
+
+			var walked []string
+			query := TopReposQuery{Sort: sort, Limit: 1}
+			for {
+				page, err := sqlite.GetTopRepos(query)
+				if err != nil {
+					t.Fatalf("GetTopRepos failed: %v", err)
+				}
+				if page.Total != 4 {
+					t.Errorf("total = %d on page %d, want 4", page.Total, len(walked)+1)
+				}
+				walked = append(walked, repoNames(page.Repos)...)
+				if page.Next == nil {
+					break
+				}
+				query.After = page.Next
+			}
+
+			if want := repoNames(all.Repos); !reflect.DeepEqual(walked, want) {
+				t.Errorf("pages returned %v, want %v", walked, want)
+			}
+		})
+	}
+
+	// A repo that moves up ahead of the cursor doesn't shift the next page
+	first, err := sqlite.GetTopRepos(TopReposQuery{Limit: 2})
+	if err != nil {
+		t.Fatalf("GetTopRepos failed: %v", err)
+	}
+	saveRepos(t, sqlite, RepoData{Username: "b", RepoName: "new", TotalLines: 900, TotalCommits: 10})
+	second, err := sqlite.GetTopRepos(TopReposQuery{Limit: 2, After: first.Next})
+	if err != nil {
+		t.Fatalf("GetTopRepos failed: %v", err)
+	}
+	got := append(repoNames(first.Repos), repoNames(second.Repos)...)
+	if want := []string{"a/one", "a/two", "a/three", "a/four"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("pages around a new repo returned %v, want %v", got, want)
+	}
+	if second.Total != 5 || second.Next != nil {
+		t.Errorf("second page total = %d, next = %v, want 5 and no next page", second.Total, second.Next)
+	}
+
+	// Once the repos after the cursor are gone, the page is empty but still counts the matches
+	third, err := sqlite.GetTopRepos(TopReposQuery{Limit: 4})
+	if err != nil {
+		t.Fatalf("GetTopRepos failed: %v", err)
+	}
+	if _, err := sqlite.DeleteRepo("a", "four"); err != nil {
+		t.Fatalf("DeleteRepo failed: %v", err)
+	}
+	empty, err := sqlite.GetTopRepos(TopReposQuery{Limit: 4, After: third.Next})
+	if err != nil {
+		t.Fatalf("GetTopRepos failed: %v", err)
+	}
+	if len(empty.Repos) != 0 || empty.Total != 4 || empty.Next != nil {
+		t.Errorf("past the last repo got %+v, want no repos and a total of 4", empty)
+	}
+}
+
+func TestSearchRepos(t *testing.T) {
+	sqlite := newMigratedSQLite(t)
+
+	saveRepos(t, sqlite,
+		RepoData{Username: "octo", RepoName: "hello-world", TotalLines: 10, TotalCommits: 2},
+		RepoData{Username: "octo", RepoName: "hello", TotalLines: 10, TotalCommits: 2},
+		RepoData{Username: "mona", RepoName: "say-hello", TotalLines: 10, TotalCommits: 2},
+		RepoData{Username: "hello", RepoName: "other", TotalLines: 10, TotalCommits: 2},
+		RepoData{Username: "octo", RepoName: "unrelated", TotalLines: 10, TotalCommits: 2},
+		RepoData{Username: "octo", RepoName: "hello_", TotalLines: 10, TotalCommits: 2},
+	)
+	if err := sqlite.IncrementViews("mona", "say-hello"); err != nil {
+		t.Fatalf("IncrementViews failed: %v", err)
+	}
+
+	// Exact name, then prefixes, then other substrings by views
+	want := []string{"octo/hello", "octo/hello-world", "octo/hello_", "mona/say-hello", "hello/other"}
+
+	var walked []string
+	var after Cursor
+	for {
+		page, err := sqlite.SearchRepos("Hello", 2, after)
+		if err != nil {
+			t.Fatalf("SearchRepos failed: %v", err)
+		}
+		if page.Total != len(want) {
+			t.Errorf("total = %d, want %d", page.Total, len(want))
+		}
+		walked = append(walked, repoNames(page.Repos)...)
+		if page.Next == nil {
+			break
+		}
+		after = page.Next
+	}
+	if !reflect.DeepEqual(walked, want) {
+		t.Errorf("SearchRepos pages returned %v, want %v", walked, want)
+	}
+
+	// LIKE wildcards in the term are matched literally
+	page, err := sqlite.SearchRepos("hello_", 10, nil)
+	if err != nil {
+		t.Fatalf("SearchRepos failed: %v", err)
+	}
+	if got := repoNames(page.Repos); !reflect.DeepEqual(got, []string{"octo/hello_"}) {
+		t.Errorf("SearchRepos(hello_) returned %v, want only octo/hello_", got)
+	}
+}
+
+func TestSaveRepoUpdates(t *testing.T) {
+	sqlite := newMigratedSQLite(t)
+
+	saveRepos(t, sqlite, RepoData{Username: "a", RepoName: "b", TotalLines: 10, TotalCommits: 2})
+	if err := sqlite.IncrementViews("a", "b"); err != nil {
+		t.Fatalf("IncrementViews failed: %v", err)
+	}
+	saveRepos(t, sqlite, RepoData{Username: "a", RepoName: "b", TotalLines: 20, TotalCommits: 3})
+
+	page, err := sqlite.GetTopRepos(TopReposQuery{})
+	if err != nil {
+		t.Fatalf("GetTopRepos failed: %v", err)
+	}
+	repos, total := page.Repos, page.Total
+	if total != 1 || repos[0].TotalLines != 20 || repos[0].TotalCommits != 3 {
+		t.Errorf("after saving twice got %d repos, %+v", total, repos)
+	}
+	if repos[0].Views != 1 {
+		t.Errorf("views = %d, saving again should keep them", repos[0].Views)
+	}
+}
+
+func TestDailyViews(t *testing.T) {
+	sqlite := newMigratedSQLite(t)
+
+	saveRepos(t, sqlite,
+		RepoData{Username: "a", RepoName: "one", TotalLines: 10, TotalCommits: 2},
+		RepoData{Username: "a", RepoName: "two", TotalLines: 10, TotalCommits: 2},
+		RepoData{Username: "a", RepoName: "hidden", TotalLines: 10, TotalCommits: 2},
+	)
+	if _, err := sqlite.SetRepoHidden("a", "hidden", true); err != nil {
+		t.Fatalf("SetRepoHidden failed: %v", err)
+	}
+
+	views := map[string]int{"one": 2, "two": 1, "hidden": 1, "unknown": 1}
+	for repo, count := range views {
+		for i := 0; i < count; i++ {
+			if err := sqlite.IncrementViews("a", repo); err != nil {
+				t.Fatalf("IncrementViews(%s) failed: %v", repo, err)
+			}
+		}
+	}
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	daily, err := sqlite.GetDailyViews(today)
+	if err != nil {
+		t.Fatalf("GetDailyViews failed: %v", err)
+	}
+	got := make(map[string]int)
+	for _, day := range daily {
+		if day.Username != "a" || !day.Day.Equal(today) {
+			t.Errorf("unexpected row %+v, want a's views on %v", day, today)
+		}
+		got[day.RepoName] = day.Views
+	}
+	if want := map[string]int{"one": 2, "two": 1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("GetDailyViews returned %v, want %v", got, want)
+	}
+
+	tomorrow, err := sqlite.GetDailyViews(today.AddDate(0, 0, 1))
+	if err != nil {
+		t.Fatalf("GetDailyViews failed: %v", err)
+	}
+	if len(tomorrow) != 0 {
+		t.Errorf("GetDailyViews since tomorrow returned %v", tomorrow)
+	}
+
+	page, err := sqlite.GetTopRepos(TopReposQuery{Sort: TopReposByViews})
+	if err != nil {
+		t.Fatalf("GetTopRepos failed: %v", err)
+	}
+	if repos := page.Repos; repos[0].RepoName != "one" || repos[0].Views != 2 {
+		t.Errorf("lifetime views: top repo %s with %d views, want one with 2", repos[0].RepoName, repos[0].Views)
+	}
+}
+
+func TestAuthorCommits(t *testing.T) {
+	sqlite := newMigratedSQLite(t)
+
+	saveRepos(t, sqlite,
+		RepoData{Username: "octo", RepoName: "one", TotalLines: 10, TotalCommits: 2},
+		RepoData{Username: "octo", RepoName: "two", TotalLines: 10, TotalCommits: 2},
+		RepoData{Username: "octo", RepoName: "hidden", TotalLines: 10, TotalCommits: 2},
+	)
+
+	base := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC).Unix()
+	commits := map[string][]CommitStats{
+		"one": {
+			{Hash: "a1", Author: "Mona", Email: "mona@example.com", Date: base + 300, TimezoneOffset: 120, Added: 5, Removed: 1, FilesTouchedCount: 2},
+			{Hash: "a2", Author: "Someone", Email: "someone@example.com", Date: base + 100},
+		},
+		"two": {
+			{Hash: "b1", Author: "Mona Lisa", Email: "mona@example.com", Date: base + 200, TimezoneOffset: -300, Added: 7},
+		},
+		"hidden": {
+			{Hash: "c1", Author: "Mona", Email: "mona@example.com", Date: base},
+		},
+	}
+	for repo, repoCommits := range commits {
+		if err := sqlite.ReplaceCommits("octo", repo, repoCommits); err != nil {
+			t.Fatalf("ReplaceCommits(%s) failed: %v", repo, err)
+		}
+	}
+	if _, err := sqlite.SetRepoHidden("octo", "hidden", true); err != nil {
+		t.Fatalf("SetRepoHidden failed: %v", err)
+	}
+
+	got, err := sqlite.GetAuthorCommits("Mona@Example.com")
+	if err != nil {
+		t.Fatalf("GetAuthorCommits failed: %v", err)
+	}
+	want := []AuthorCommit{
+		{Username: "octo", RepoName: "two", CommitStats: CommitStats{Hash: "b1", Author: "Mona Lisa", Date: base + 200, TimezoneOffset: -300, Added: 7}},
+		{Username: "octo", RepoName: "one", CommitStats: CommitStats{Hash: "a1", Author: "Mona", Date: base + 300, TimezoneOffset: 120, Added: 5, Removed: 1, FilesTouchedCount: 2}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GetAuthorCommits returned\n%+v\nwant\n%+v", got, want)
+	}
+
+	// Replacing drops the previous history
+	if err := sqlite.ReplaceCommits("octo", "one", commits["one"][1:]); err != nil {
+		t.Fatalf("ReplaceCommits failed: %v", err)
+	}
+	got, err = sqlite.GetAuthorCommits("mona@example.com")
+	if err != nil {
+		t.Fatalf("GetAuthorCommits failed: %v", err)
+	}
+	if len(got) != 1 || got[0].Hash != "b1" {
+		t.Errorf("after replacing one's commits got %+v, want only b1", got)
+	}
+
+	// A repo's commit can only be stored once, the failed replace keeps the history it would have dropped
+	twice := []CommitStats{commits["one"][0], commits["one"][0]}
+	if err := sqlite.ReplaceCommits("octo", "two", twice); err == nil {
+		t.Error("ReplaceCommits stored the same commit twice")
+	}
+	got, err = sqlite.GetAuthorCommits("mona@example.com")
+	if err != nil {
+		t.Fatalf("GetAuthorCommits failed: %v", err)
+	}
+	if len(got) != 1 || got[0].Hash != "b1" {
+		t.Errorf("after a failed replace got %+v, want b1 still there", got)
+	}
+
+	if err := sqlite.ReplaceCommits("octo", "missing", commits["one"]); err == nil {
+		t.Error("ReplaceCommits succeeded for a repo that was never saved")
+	}
+}
+
+func TestSnapshots(t *testing.T) {
+	sqlite := newMigratedSQLite(t)
+
+	saveRepos(t, sqlite, RepoData{Username: "octo", RepoName: "hello", TotalLines: 10, TotalCommits: 2})
+
+	base := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	snapshots := []RepoSnapshot{
+		{AnalyzedAt: base.Add(48 * time.Hour), HeadSHA: "ccc", TotalCommits: 30, TotalLines: 300, TotalStars: 3, Language: "Go"},
+		{AnalyzedAt: base, HeadSHA: "aaa", TotalCommits: 10, TotalAdditions: 150, TotalRemovals: 50, TotalLines: 100,
+			TotalContributors: 2, TotalStars: 1, Language: "Go"},
+		{AnalyzedAt: base.Add(24 * time.Hour), HeadSHA: "bbb", TotalCommits: 20, TotalLines: 200, TotalStars: 2, Language: "Go"},
+	}
+	for _, snapshot := range snapshots {
+		if err := sqlite.SaveSnapshot("octo", "hello", snapshot); err != nil {
+			t.Fatalf("SaveSnapshot failed: %v", err)
+		}
+	}
+
+	got, err := sqlite.GetSnapshots("octo", "hello", base)
+	if err != nil {
+		t.Fatalf("GetSnapshots failed: %v", err)
+	}
+	if len(got) != 3 {
+		t.Fatalf("GetSnapshots returned %d snapshots, want 3", len(got))
+	}
+	for i, sha := range []string{"aaa", "bbb", "ccc"} {
+		if got[i].HeadSHA != sha {
+			t.Errorf("snapshot %d is %s, want %s, oldest first", i, got[i].HeadSHA, sha)
+		}
+	}
+	first := got[0]
+	first.AnalyzedAt = first.AnalyzedAt.UTC()
+	if !reflect.DeepEqual(first, snapshots[1]) {
+		t.Errorf("snapshot read back as %+v, want %+v", first, snapshots[1])
+	}
+
+	recent, err := sqlite.GetSnapshots("octo", "hello", base.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("GetSnapshots failed: %v", err)
+	}
+	if len(recent) != 2 || recent[0].HeadSHA != "bbb" {
+		t.Errorf("GetSnapshots since an hour later returned %+v, want bbb and ccc", recent)
+	}
+
+	none, err := sqlite.GetSnapshots("octo", "other", base)
+	if err != nil {
+		t.Fatalf("GetSnapshots failed: %v", err)
+	}
+	if len(none) != 0 {
+		t.Errorf("GetSnapshots of an unknown repo returned %+v", none)
+	}
+
+	if err := sqlite.SaveSnapshot("octo", "other", snapshots[0]); err == nil {
+		t.Error("SaveSnapshot succeeded for a repo that was never saved")
+	}
+}
+
+func TestAuthorCommitsByHandle(t *testing.T) {
+	sqlite := newMigratedSQLite(t)
+
+	saveRepos(t, sqlite, RepoData{Username: "octo", RepoName: "hello", TotalLines: 10, TotalCommits: 2})
+	base := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC).Unix()
+	commits := []CommitStats{
+		{Hash: "a", Author: "Mona", Email: "mona@example.com", Date: base},
+		{Hash: "b", Author: "M. Lisa", Email: "mona@users.noreply.github.com", Date: base + 1},
+		{Hash: "c", Author: "M. Lisa", Email: "583231+mona@users.noreply.github.com", Date: base + 2},
+		{Hash: "d", Author: "Other", Email: "583231+monalisa@users.noreply.github.com", Date: base + 3},
+		{Hash: "e", Author: "Other", Email: "mona_x@users.noreply.github.com", Date: base + 4},
+		{Hash: "f", Author: "Other", Email: "12+MONA@Users.Noreply.GitHub.com", Date: base + 5},
+		{Hash: "g", Author: "Other", Email: "mona+x@example.com", Date: base + 6},
+	}
+	if err := sqlite.ReplaceCommits("octo", "hello", commits); err != nil {
+		t.Fatalf("ReplaceCommits failed: %v", err)
+	}
+
+	tests := map[string][]string{
+		"Mona":    {"a", "b", "c", "f"},
+		"mona_":   {},
+		"mona%":   {},
+		"mona_x":  {"e"},
+		"x":       {},
+		"583231":  {},
+		"monalis": {},
+	}
+	check := func(phase string) {
+		for identity, want := range tests {
+			got, err := sqlite.GetAuthorCommits(identity)
+			if err != nil {
+				t.Fatalf("GetAuthorCommits(%q) failed: %v", identity, err)
+			}
+			hashes := []string{}
+			for _, commit := range got {
+				hashes = append(hashes, commit.Hash)
+			}
+			if !reflect.DeepEqual(hashes, want) {
+				t.Errorf("%s: GetAuthorCommits(%q) returned %v, want %v", phase, identity, hashes, want)
+			}
+		}
+	}
+	check("saved")
+
+	// Commits stored before handles were, the migration fills them in
+	if err := MigrateDown(1); err != nil {
+		t.Fatalf("MigrateDown failed: %v", err)
+	}
+	if err := MigrateUp(); err != nil {
+		t.Fatalf("MigrateUp failed: %v", err)
+	}
+	check("backfilled")
+}
+
+func TestAuthorCommitsCap(t *testing.T) {
+	sqlite := newMigratedSQLite(t)
+
+	saveRepos(t, sqlite, RepoData{Username: "octo", RepoName: "hello", TotalLines: 10, TotalCommits: 2})
+	base := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC).Unix()
+	commits := make([]CommitStats, MaxAuthorCommits+10)
+	for i := range commits {
+		commits[i] = CommitStats{Hash: fmt.Sprint(i), Author: "Mona", Email: "mona@example.com", Date: base + int64(i)*60}
+	}
+	if err := sqlite.ReplaceCommits("octo", "hello", commits); err != nil {
+		t.Fatalf("ReplaceCommits failed: %v", err)
+	}
+
+	got, err := sqlite.GetAuthorCommits("mona@example.com")
+	if err != nil {
+		t.Fatalf("GetAuthorCommits failed: %v", err)
+	}
+	if len(got) != MaxAuthorCommits {
+		t.Fatalf("GetAuthorCommits returned %d commits, want %d", len(got), MaxAuthorCommits)
+	}
+	// The most recent ones, still oldest first
+	if got[0].Hash != "10" || got[len(got)-1].Hash != fmt.Sprint(len(commits)-1) {
+		t.Errorf("GetAuthorCommits returned %s to %s, want 10 to %d", got[0].Hash, got[len(got)-1].Hash, len(commits)-1)
+	}
+}
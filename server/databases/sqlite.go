@@ -0,0 +1,80 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// SQLiteRepository stores repositories in a SQLite file, for self-hosting without a database server
+type SQLiteRepository struct {
+	sqlRepository
+}
+
+// NewSQLiteRepository opens or creates the SQLite database at path, :memory: keeps it in memory
+func NewSQLiteRepository(path string) (*SQLiteRepository, error) {
+	// Writes come from several goroutines, wait for the lock instead of failing with SQLITE_BUSY
+	dsn := fmt.Sprintf("file:%s?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=foreign_keys(1)", path)
+
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	if err := db.Ping(); err != nil {
+		return nil, fmt.Errorf("failed to ping database: %w", err)
+	}
+
+	// SQLite has a single writer, one connection avoids lock contention and keeps :memory: to one database
+	db.SetMaxOpenConns(1)
+
+	log.Printf("SQLite database opened at %s", path)
+
+	return &SQLiteRepository{sqlRepository{db: db}}, nil
+}
+
+func (s *SQLiteRepository) migrator() *migrator {
+	return &migrator{db: s.db, files: migrationFiles, dir: "migrations/sqlite"}
+}
+
+// ReplaceCommits inserts commits with a prepared statement in a single transaction
+func (s *SQLiteRepository) ReplaceCommits(username, repoName string, commits []CommitStats) error {
+	start := time.Now()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// SQLite has no FOR UPDATE, its single connection already runs one transaction at a time
+	repoID, err := clearCommits(tx, username, repoName, false)
+	if err != nil {
+		return err
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(commitColumns)), ", ")
+	stmt, err := tx.Prepare(fmt.Sprintf("INSERT INTO commits (%s) VALUES (%s)",
+		strings.Join(commitColumns, ", "), placeholders))
+	if err != nil {
+		return fmt.Errorf("failed to prepare insert: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, commit := range commits {
+		if _, err := stmt.Exec(commitValues(repoID, commit)...); err != nil {
+			return fmt.Errorf("failed to insert commit: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	log.Printf("Saved %d commits for %s/%s (took %v)", len(commits), username, repoName, time.Since(start))
+	return nil
+}
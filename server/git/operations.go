@@ -85,7 +85,7 @@ func (r *Repository) AnalyzeCommits() ([]database.CommitStats, error) {
 		"--git-dir", r.Path,
 		"log",
 		"--numstat",
-		"--format=%H|%an|%at|%s",
+		"--format=%H|%an|%ae|%at|%ai|%s",
 	)
 
 	stdout, err := cmd.StdoutPipe()
@@ -124,17 +124,19 @@ func (r *Repository) AnalyzeCommits() ([]database.CommitStats, error) {
 				commits = append(commits, *currentCommit)
 			}
 
-			parts := strings.SplitN(line, "|", 4)
-			if len(parts) != 4 {
+			parts := strings.SplitN(line, "|", 6)
+			if len(parts) != 6 {
 				continue
 			}
 
-			timestamp, _ := strconv.ParseInt(parts[2], 10, 64)
+			timestamp, _ := strconv.ParseInt(parts[3], 10, 64)
 			currentCommit = &database.CommitStats{
-				Hash:              parts[0][:min(7, len(parts[0]))],
+				Hash:              parts[0],
 				Author:            parts[1],
+				Email:             strings.ToLower(parts[2]),
 				Date:              timestamp,
-				Message:           truncateMessage(parts[3], 100),
+				TimezoneOffset:    parseTimezoneOffset(parts[4]),
+				Message:           truncateMessage(parts[5], 100),
 				Added:             0,
 				Removed:           0,
 				FilesTouchedCount: 0,
@@ -171,6 +173,162 @@ func (r *Repository) AnalyzeCommits() ([]database.CommitStats, error) {
 	return commits, nil
 }
 
+// HeadSHA returns the commit the cloned repository's HEAD points at
+func (r *Repository) HeadSHA() (string, error) {
+	out, err := exec.CommandContext(r.ctx, "git", "--git-dir", r.Path, "rev-parse", "HEAD").Output()
+	if err != nil {
+		return "", fmt.Errorf("git rev-parse failed: %w", err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// RemoteHead returns the commit HEAD points at on the remote without cloning, which is cheap enough to
+// check before serving a cached analysis
+func RemoteHead(repoURL string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "git", "ls-remote", repoURL, "HEAD")
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return "", fmt.Errorf("git ls-remote timeout")
+		}
+		return "", fmt.Errorf("git ls-remote failed: %w, stderr: %s", err, stderr.String())
+	}
+
+	// Output is "<sha>\tHEAD"
+	fields := strings.Fields(string(out))
+	if len(fields) < 2 || fields[1] != "HEAD" {
+		return "", fmt.Errorf("unexpected git ls-remote output: %q", string(out))
+	}
+	return fields[0], nil
+}
+
+// FileChange is a single numstat entry: one path touched by one commit
+type FileChange struct {
+	Author  string
+	Email   string
+	Date    int64
+	Path    string
+	Added   int
+	Removed int
+}
+
+// AnalyzeFileChanges extracts per-path change history, optionally limited to commits after since
+func (r *Repository) AnalyzeFileChanges(since time.Time) ([]FileChange, error) {
+	args := []string{
+		"--git-dir", r.Path,
+		"log",
+		"--numstat",
+		"--no-renames",
+		"--format=%x00%an|%ae|%at",
+	}
+	if !since.IsZero() {
+		args = append(args, fmt.Sprintf("--since=%d", since.Unix()))
+	}
+
+	cmd := exec.CommandContext(r.ctx, "git", args...)
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create stdout pipe: %w", err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start git log: %w", err)
+	}
+
+	changes := make([]FileChange, 0, 4096)
+	scanner := bufio.NewScanner(stdout)
+
+	buf := make([]byte, 0, 1024*1024) // 1MB buffer
+	scanner.Buffer(buf, 10*1024*1024) // 10MB max
+
+	var author, email string
+	var date int64
+
+	for scanner.Scan() {
+		select {
+		case <-r.ctx.Done():
+			return nil, fmt.Errorf("analysis cancelled: %w", r.ctx.Err())
+		default:
+		}
+
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+
+		// Commit headers are prefixed with a NUL byte so paths containing "|" can't be mistaken for them
+		if line[0] == 0 {
+			parts := strings.SplitN(line[1:], "|", 3)
+			if len(parts) != 3 {
+				author = ""
+				continue
+			}
+			author = parts[0]
+			email = parts[1]
+			date, _ = strconv.ParseInt(parts[2], 10, 64)
+			continue
+		}
+
+		if author == "" {
+			continue
+		}
+
+		fields := strings.SplitN(line, "\t", 3)
+		if len(fields) < 3 {
+			continue
+		}
+
+		// Binary files report "-" for both counts, they still count as a touch
+		added, _ := strconv.Atoi(fields[0])
+		removed, _ := strconv.Atoi(fields[1])
+
+		changes = append(changes, FileChange{
+			Author:  author,
+			Email:   email,
+			Date:    date,
+			Path:    fields[2],
+			Added:   added,
+			Removed: removed,
+		})
+	}
+
+	if err := cmd.Wait(); err != nil {
+		return nil, fmt.Errorf("git log failed: %w", err)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("scanner error: %w", err)
+	}
+
+	return changes, nil
+}
+
+// ReadFile returns the contents of a file at HEAD
+func (r *Repository) ReadFile(path string) ([]byte, error) {
+	cmd := exec.CommandContext(r.ctx, "git",
+		"--git-dir", r.Path,
+		"show",
+		"HEAD:"+path,
+	)
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git show failed: %w, stderr: %s", err, stderr.String())
+	}
+
+	return out, nil
+}
+
 // Cleanup removes temporary files and cancels context
 func (r *Repository) Cleanup() {
 	if r.cancel != nil {
@@ -195,6 +353,26 @@ func ValidateRepoURL(repoURL string) error {
 	return nil
 }
 
+// parseTimezoneOffset reads the "+0200" suffix of an ISO-like git date and returns minutes east of UTC
+func parseTimezoneOffset(isoDate string) int {
+	if len(isoDate) < 5 {
+		return 0
+	}
+
+	zone := isoDate[len(isoDate)-5:]
+	hours, err1 := strconv.Atoi(zone[1:3])
+	minutes, err2 := strconv.Atoi(zone[3:5])
+	if err1 != nil || err2 != nil {
+		return 0
+	}
+
+	offset := hours*60 + minutes
+	if zone[0] == '-' {
+		offset = -offset
+	}
+	return offset
+}
+
 func truncateMessage(msg string, maxLen int) string {
 	if len(msg) <= maxLen {
 		return msg
@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	database "github.com/immatheus/gitback/databases"
+	"github.com/immatheus/gitback/middleware"
+	"github.com/immatheus/gitback/storage"
+)
+
+// CacheEntryInfo is a cached object as listed by the admin API
+type CacheEntryInfo struct {
+	storage.CacheEntry
+	AgeSeconds int64 `json:"ageSeconds"`
+}
+
+// AdminListCache lists cached objects whose key starts with ?prefix= (cache/ by default) with their age
+// and size
+func AdminListCache(c *fiber.Ctx) error {
+	entries, err := storage.ListCacheEntries(c.Query("prefix", "cache/"))
+	if err != nil {
+		log.Printf("[ADMIN] Failed to list cache: %v", err)
+		return middleware.InternalError(c, "Failed to list cache")
+	}
+
+	now := time.Now()
+	infos := make([]CacheEntryInfo, 0, len(entries))
+	var totalSize int64
+	for _, entry := range entries {
+		infos = append(infos, CacheEntryInfo{
+			CacheEntry: entry,
+			AgeSeconds: int64(now.Sub(entry.Updated).Seconds()),
+		})
+		totalSize += entry.Size
+	}
+
+	return c.JSON(fiber.Map{
+		"entries":   infos,
+		"total":     len(infos),
+		"totalSize": totalSize,
+	})
+}
+
+// AdminPurgeCache deletes every cached object of a repository
+func AdminPurgeCache(c *fiber.Ctx) error {
+	req, err := repoFromParams(c)
+	if err != nil {
+		return middleware.ValidationError(c, err.Error())
+	}
+
+	deleted, err := storage.ClearCache(req.Username, req.Repo)
+	if err != nil {
+		log.Printf("[ADMIN] Failed to purge cache for %s/%s: %v", req.Username, req.Repo, err)
+		return middleware.InternalError(c, "Failed to purge cache")
+	}
+
+	log.Printf("[ADMIN] Purged cache for %s/%s", req.Username, req.Repo)
+	return c.JSON(fiber.Map{
+		"deleted": deleted,
+	})
+}
+
+// AdminReanalyze purges a repository's cache and analyzes it again, without counting a view
+func AdminReanalyze(c *fiber.Ctx) error {
+	req, err := repoFromParams(c)
+	if err != nil {
+		return middleware.ValidationError(c, err.Error())
+	}
+
+	if _, err := storage.ClearCache(req.Username, req.Repo); err != nil {
+		log.Printf("[ADMIN] Failed to purge cache before re-analyzing %s/%s: %v", req.Username, req.Repo, err)
+	}
+
+	response, _, err := runAnalysis(req.Username, req.Repo, false)
+	if err != nil {
+		return analysisError(c, err)
+	}
+
+	log.Printf("[ADMIN] Re-analyzed %s/%s", req.Username, req.Repo)
+	return c.JSON(withoutCommits(response))
+}
+
+// AdminDeleteRepo removes a repository from the database, its cache is left alone
+func AdminDeleteRepo(c *fiber.Ctx) error {
+	req, err := repoFromParams(c)
+	if err != nil {
+		return middleware.ValidationError(c, err.Error())
+	}
+
+	deleted, err := database.DeleteRepo(req.Username, req.Repo)
+	if err != nil {
+		log.Printf("[ADMIN] Failed to delete %s/%s: %v", req.Username, req.Repo, err)
+		return middleware.InternalError(c, "Failed to delete repository")
+	}
+	if !deleted {
+		return middleware.NotFoundError(c, "Repository not found")
+	}
+
+	log.Printf("[ADMIN] Deleted %s/%s from the database", req.Username, req.Repo)
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// AdminHideRepo hides a repository from the top repos
+func AdminHideRepo(c *fiber.Ctx) error {
+	return setRepoHidden(c, true)
+}
+
+// AdminUnhideRepo shows a hidden repository in the top repos again
+func AdminUnhideRepo(c *fiber.Ctx) error {
+	return setRepoHidden(c, false)
+}
+
+func setRepoHidden(c *fiber.Ctx, hidden bool) error {
+	req, err := repoFromParams(c)
+	if err != nil {
+		return middleware.ValidationError(c, err.Error())
+	}
+
+	found, err := database.SetRepoHidden(req.Username, req.Repo, hidden)
+	if err != nil {
+		log.Printf("[ADMIN] Failed to set hidden=%v for %s/%s: %v", hidden, req.Username, req.Repo, err)
+		return middleware.InternalError(c, "Failed to update repository")
+	}
+	if !found {
+		return middleware.NotFoundError(c, "Repository not found")
+	}
+
+	log.Printf("[ADMIN] Set hidden=%v for %s/%s", hidden, req.Username, req.Repo)
+	return c.JSON(fiber.Map{
+		"username": req.Username,
+		"repoName": req.Repo,
+		"hidden":   hidden,
+	})
+}
+
+// AdminListExclusions lists the repos left out of the top repos
+func AdminListExclusions(c *fiber.Ctx) error {
+	exclusions, err := database.ListExclusions()
+	if err != nil {
+		log.Printf("[ADMIN] Failed to list exclusions: %v", err)
+		return middleware.InternalError(c, "Failed to list exclusions")
+	}
+
+	return c.JSON(fiber.Map{
+		"exclusions": exclusions,
+	})
+}
+
+// AdminAddExclusion leaves a repo out of the top repos. The body is {"username", "repoName", "reason"}, an
+// empty username excludes the repo name under every owner
+func AdminAddExclusion(c *fiber.Ctx) error {
+	var exclusion database.Exclusion
+	if err := c.BodyParser(&exclusion); err != nil {
+		return middleware.ValidationError(c, "Invalid request body")
+	}
+	if err := validateExclusion(exclusion.Username, exclusion.RepoName); err != nil {
+		return middleware.ValidationError(c, err.Error())
+	}
+
+	if err := database.AddExclusion(exclusion); err != nil {
+		log.Printf("[ADMIN] Failed to add exclusion for %s/%s: %v", exclusion.Username, exclusion.RepoName, err)
+		return middleware.InternalError(c, "Failed to add exclusion")
+	}
+
+	log.Printf("[ADMIN] Excluded %s/%s from top repos", exclusion.Username, exclusion.RepoName)
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"username": exclusion.Username,
+		"repoName": exclusion.RepoName,
+		"reason":   exclusion.Reason,
+	})
+}
+
+// AdminRemoveExclusion puts the repo in ?owner=&repo= back in the top repos
+func AdminRemoveExclusion(c *fiber.Ctx) error {
+	username, repoName := c.Query("owner"), c.Query("repo")
+	if err := validateExclusion(username, repoName); err != nil {
+		return middleware.ValidationError(c, err.Error())
+	}
+
+	removed, err := database.RemoveExclusion(username, repoName)
+	if err != nil {
+		log.Printf("[ADMIN] Failed to remove exclusion for %s/%s: %v", username, repoName, err)
+		return middleware.InternalError(c, "Failed to remove exclusion")
+	}
+	if !removed {
+		return middleware.NotFoundError(c, "Exclusion not found")
+	}
+
+	log.Printf("[ADMIN] Removed exclusion of %s/%s from top repos", username, repoName)
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func validateExclusion(username, repoName string) error {
+	if repoName == "" {
+		return fmt.Errorf("repository name is required")
+	}
+	if len(username) > 100 || len(repoName) > 100 {
+		return fmt.Errorf("username and repository name must be 100 characters or less")
+	}
+	if containsUnsafeChars(username) || containsUnsafeChars(repoName) {
+		return fmt.Errorf("username and repository name contain invalid characters")
+	}
+	return nil
+}
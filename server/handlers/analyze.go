@@ -2,15 +2,18 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/immatheus/gitback/analysis"
 	database "github.com/immatheus/gitback/databases"
 	"github.com/immatheus/gitback/git"
 	"github.com/immatheus/gitback/middleware"
@@ -20,6 +23,8 @@ import (
 type AnalyzeRequest struct {
 	Username string `json:"username" validate:"required,min=1,max=255"`
 	Repo     string `json:"repo" validate:"required,min=1,max=255"`
+	// OmitCommits leaves the full commits array out of the response, use the commits endpoint to page through it
+	OmitCommits bool `json:"omitCommits"`
 }
 
 type GitHubRepo struct {
@@ -73,6 +78,11 @@ var githubClient = &http.Client{
 	Timeout: 15 * time.Second,
 }
 
+var (
+	errRepoNotFound = errors.New("repository not found")
+	errCloneFailed  = errors.New("failed to clone repository")
+)
+
 func AnalyzeRepo(c *fiber.Ctx) error {
 	requestStart := time.Now()
 
@@ -96,10 +106,14 @@ func AnalyzeRepo(c *fiber.Ctx) error {
 
 	log.Printf("=== Starting analysis for: %s ===", repoURL)
 
-	if cachedData, err := storage.GetFromCache(req.Username, req.Repo); err != nil {
+	if cached, err := storage.GetCachedAnalysis(req.Username, req.Repo); err != nil {
 		log.Printf("Cache check failed: %v", err)
-	} else if cachedData != nil {
-		log.Printf("Returning cached analysis for %s", repoURL)
+	} else if cached != nil && checkFreshness(repoURL, cached) {
+		log.Printf("Returning cached analysis for %s (stale: %v)", repoURL, cached.Stale)
+
+		if cached.Stale {
+			refreshInBackground(req.Username, req.Repo)
+		}
 
 		// Update view count in background
 		go func() {
@@ -108,25 +122,117 @@ func AnalyzeRepo(c *fiber.Ctx) error {
 			}
 		}()
 
-		return c.JSON(cachedData)
+		return sendCachedAnalysis(c, cached, req.OmitCommits)
+	}
+
+	response, _, err := runAnalysis(req.Username, req.Repo, true)
+	if err != nil {
+		return analysisError(c, err)
+	}
+
+	if req.OmitCommits {
+		response = withoutCommits(response)
+	}
+
+	log.Printf("[TIMING] Total request time: %v", time.Since(requestStart))
+	return sendNegotiated(c, response)
+}
+
+// storedAnalysis is what the endpoints derived from an analysis are computed from
+type storedAnalysis struct {
+	commits []database.CommitStats
+	headSHA string // empty when unknown
+}
+
+// analysisCall is an analysis in progress, runAnalysis calls for the same repo wait for its result
+type analysisCall struct {
+	done     chan struct{}
+	response fiber.Map
+	stored   storedAnalysis
+	err      error
+}
+
+// analyzing holds the cache keys of repos being analyzed, with their *analysisCall
+var analyzing sync.Map
+
+// defaultAnalysisWorkers is how many repos background jobs analyze at once when ANALYSIS_WORKERS isn't set
+const defaultAnalysisWorkers = 4
+
+var (
+	analysisWorkersOnce sync.Once
+	// analysisSlotsChan bounds how many repos org and compare jobs analyze at once, across all jobs
+	analysisSlotsChan chan struct{}
+)
+
+// analysisSlots returns the semaphore shared by org and compare jobs, sized by ANALYSIS_WORKERS
+func analysisSlots() chan struct{} {
+	analysisWorkersOnce.Do(func() {
+		workers := defaultAnalysisWorkers
+		if value := os.Getenv("ANALYSIS_WORKERS"); value != "" {
+			if n, err := strconv.Atoi(value); err == nil && n > 0 {
+				workers = n
+			} else {
+				log.Printf("Invalid ANALYSIS_WORKERS %q, using %d", value, defaultAnalysisWorkers)
+			}
+		}
+		analysisSlotsChan = make(chan struct{}, workers)
+	})
+	return analysisSlotsChan
+}
+
+// runAnalysis clones and analyzes a repository, then saves the result to the database and cache in the
+// background. countView also records a view of the repo once it's saved. Concurrent calls for the same
+// repo share one clone and analysis, so its commits are never saved twice at once
+func runAnalysis(username, repoName string, countView bool) (fiber.Map, storedAnalysis, error) {
+	key := storage.CacheKey(username, repoName)
+	call := &analysisCall{done: make(chan struct{})}
+	if running, loaded := analyzing.LoadOrStore(key, call); loaded {
+		running := running.(*analysisCall)
+		<-running.done
+		if countView && running.err == nil {
+			go func() {
+				if err := database.IncrementViews(username, repoName); err != nil {
+					log.Printf("[DB] Failed to increment views for %s/%s: %v", username, repoName, err)
+				}
+			}()
+		}
+		return running.response, running.stored, running.err
 	}
 
+	defer func() {
+		analyzing.Delete(key)
+		close(call.done)
+	}()
+	call.response, call.stored, call.err = analyzeRepository(username, repoName, countView)
+	return call.response, call.stored, call.err
+}
+
+// analyzeRepository does the work of runAnalysis
+func analyzeRepository(username, repoName string, countView bool) (fiber.Map, storedAnalysis, error) {
+	repoURL := fmt.Sprintf("https://github.com/%s/%s.git", username, repoName)
+
 	// Clone and analyze repository with improved git operations
 	repo, err := git.CloneRepository(repoURL)
 	if err != nil {
 		if isNotFoundError(err) {
 			log.Printf("Repository not found: %s - Error: %v", repoURL, err)
-			return middleware.NotFoundError(c, "Repository not found")
+			return nil, storedAnalysis{}, fmt.Errorf("%w: %v", errRepoNotFound, err)
 		}
 		log.Printf("Failed to clone repository: %s - Error: %v", repoURL, err)
-		return middleware.InternalError(c, "Failed to clone repository")
+		return nil, storedAnalysis{}, fmt.Errorf("%w: %v", errCloneFailed, err)
 	}
 	defer repo.Cleanup()
 
+	headSHA, err := repo.HeadSHA()
+	if err != nil {
+		// Without it the cache entry just falls back to expiring by age
+		log.Printf("Failed to read HEAD of %s: %v", repoURL, err)
+	}
+
 	commits, err := repo.AnalyzeCommits()
 	if err != nil {
 		log.Printf("Failed to analyze commits for %s: %v", repoURL, err)
-		return middleware.InternalError(c, "Failed to analyze repository")
+		return nil, storedAnalysis{}, err
 	}
 
 	// Process statistics
@@ -156,7 +262,7 @@ func AnalyzeRepo(c *fiber.Ctx) error {
 
 	go func() {
 		defer wg.Done()
-		if repoInfo, err := fetchGitHubRepoInfo(req.Username, req.Repo); err == nil {
+		if repoInfo, err := fetchGitHubRepoInfo(username, repoName); err == nil {
 			githubInfo = repoInfo
 		} else {
 			log.Printf("Failed to fetch GitHub repo info: %v", err)
@@ -165,7 +271,7 @@ func AnalyzeRepo(c *fiber.Ctx) error {
 
 	go func() {
 		defer wg.Done()
-		if pullRequestInfo, err := fetchRepoTopPullRequests(req.Username, req.Repo); err == nil {
+		if pullRequestInfo, err := fetchRepoTopPullRequests(username, repoName); err == nil {
 			pullRequests = pullRequestInfo
 		} else {
 			log.Printf("Failed to fetch top pull requests: %v", err)
@@ -180,46 +286,188 @@ func AnalyzeRepo(c *fiber.Ctx) error {
 		totalLines := totalAdded - totalRemoved
 
 		dbData := database.RepoData{
-			Username:       req.Username,
-			RepoName:       req.Repo,
+			Username:       username,
+			RepoName:       repoName,
 			TotalAdditions: totalAdded,
 			TotalLines:     totalLines,
 			TotalRemovals:  totalRemoved,
 			LinesHistogram: histogram,
-			TotalStars:     githubInfo.StargazersCount,
 			TotalCommits:   len(commits),
-			Language:       githubInfo.Language,
-			Size:           githubInfo.Size,
+		}
+		if githubInfo != nil {
+			dbData.TotalStars = githubInfo.StargazersCount
+			dbData.Language = githubInfo.Language
+			dbData.Size = githubInfo.Size
 		}
 
 		if err := database.SaveRepo(dbData); err != nil {
 			log.Printf("[DB] Failed to save repo to database for %s: %v", repoURL, err)
+		} else {
+			snapshot := database.RepoSnapshot{
+				AnalyzedAt:        time.Now(),
+				HeadSHA:           headSHA,
+				TotalCommits:      len(commits),
+				TotalAdditions:    totalAdded,
+				TotalRemovals:     totalRemoved,
+				TotalLines:        totalLines,
+				TotalContributors: totalContributors,
+				TotalStars:        dbData.TotalStars,
+				Language:          dbData.Language,
+			}
+			if err := database.SaveSnapshot(username, repoName, snapshot); err != nil {
+				log.Printf("[DB] Failed to save snapshot to database for %s: %v", repoURL, err)
+			}
+
+			if err := database.ReplaceCommits(username, repoName, commits); err != nil {
+				log.Printf("[DB] Failed to save commits to database for %s: %v", repoURL, err)
+			}
 		}
 
-		if err := database.IncrementViews(req.Username, req.Repo); err != nil {
+		if !countView {
+			return
+		}
+		if err := database.IncrementViews(username, repoName); err != nil {
 			log.Printf("[DB] Failed to increment views for %s: %v", repoURL, err)
 		}
 	}()
 
+	// The commits table keeps full hashes, clients and the cache get them abbreviated
+	clientCommits := shortHashes(commits)
+
 	response := fiber.Map{
 		"totalAdded":        totalAdded,
 		"totalRemoved":      totalRemoved,
 		"totalContributors": totalContributors,
 		"totalCommits":      len(commits),
-		"commits":           commits,
+		"commits":           clientCommits,
 		"github":            githubInfo,
 		"pullRequests":      pullRequests,
+		"activity":          analysis.AnalyzeActivity(commits),
+		"contributors":      analysis.AnalyzeContributors(commits),
+		"streaks":           analysis.AnalyzeStreaks(commits),
 	}
 
 	// Store in cache asynchronously
 	go func() {
-		if err := storage.StoreInCache(req.Username, req.Repo, response); err != nil {
+		if err := storage.StoreInCache(username, repoName, headSHA, response); err != nil {
 			log.Printf("Failed to store analysis in cache for %s: %v", repoURL, err)
 		}
 	}()
 
-	log.Printf("[TIMING] Total request time: %v", time.Since(requestStart))
-	return c.JSON(response)
+	return response, storedAnalysis{commits: clientCommits, headSHA: headSHA}, nil
+}
+
+// shortHashLength is how many characters of a commit hash clients are sent
+const shortHashLength = 7
+
+// shortHashes copies commits with their hashes abbreviated to shortHashLength
+func shortHashes(commits []database.CommitStats) []database.CommitStats {
+	short := make([]database.CommitStats, len(commits))
+	for i, commit := range commits {
+		commit.Hash = commit.Hash[:min(shortHashLength, len(commit.Hash))]
+		short[i] = commit
+	}
+	return short
+}
+
+// withoutCommits copies an analysis response without its commits, the original is still being cached
+func withoutCommits(response fiber.Map) fiber.Map {
+	trimmed := make(fiber.Map, len(response))
+	for k, v := range response {
+		if k != "commits" {
+			trimmed[k] = v
+		}
+	}
+	return trimmed
+}
+
+// loadAnalysis returns the commits of a repository from its cached analysis the same way /analyze would
+// serve it: fresh by HEAD or age, stale ones refreshed in the background. It only analyzes on a miss,
+// countView then records a view of the repo
+func loadAnalysis(username, repoName string, countView bool) (storedAnalysis, error) {
+	if stored, ok := cachedAnalysis(username, repoName); ok {
+		return stored, nil
+	}
+
+	_, stored, err := runAnalysis(username, repoName, countView)
+	return stored, err
+}
+
+// cachedAnalysis returns the commits of a repository's cached analysis when it can still be served, stale
+// ones are refreshed in the background
+func cachedAnalysis(username, repoName string) (storedAnalysis, bool) {
+	repoURL := fmt.Sprintf("https://github.com/%s/%s.git", username, repoName)
+
+	cached, err := storage.GetCachedAnalysis(username, repoName)
+	if err != nil {
+		log.Printf("Cache check failed: %v", err)
+		return storedAnalysis{}, false
+	}
+	if cached == nil || !checkFreshness(repoURL, cached) {
+		return storedAnalysis{}, false
+	}
+
+	var body struct {
+		Commits []database.CommitStats `json:"commits"`
+	}
+	if err := json.Unmarshal(cached.Data, &body); err != nil {
+		log.Printf("Failed to unmarshal cached analysis of %s: %v", repoURL, err)
+		return storedAnalysis{}, false
+	}
+	if cached.Stale {
+		refreshInBackground(username, repoName)
+	}
+	return storedAnalysis{commits: body.Commits, headSHA: cached.HeadSHA}, true
+}
+
+// refreshing holds the cache keys of repos and org reports being refreshed in the background
+var refreshing sync.Map
+
+// refreshInBackground re-analyzes a repo whose cached analysis went stale, at most once at a time per repo
+func refreshInBackground(username, repoName string) {
+	key := storage.CacheKey(username, repoName)
+	if _, running := refreshing.LoadOrStore(key, true); running {
+		return
+	}
+
+	go func() {
+		defer refreshing.Delete(key)
+
+		log.Printf("Refreshing stale analysis for %s/%s", username, repoName)
+		if _, _, err := runAnalysis(username, repoName, false); err != nil {
+			log.Printf("Failed to refresh stale analysis for %s/%s: %v", username, repoName, err)
+		}
+	}()
+}
+
+// analysisError turns an error from runAnalysis into the matching error response
+func analysisError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, errRepoNotFound):
+		return middleware.NotFoundError(c, "Repository not found")
+	case errors.Is(err, errCloneFailed):
+		return middleware.InternalError(c, "Failed to clone repository")
+	default:
+		return middleware.InternalError(c, "Failed to analyze repository")
+	}
+}
+
+// repoFromParams reads and validates the :owner and :repo route params
+func repoFromParams(c *fiber.Ctx) (AnalyzeRequest, error) {
+	req := AnalyzeRequest{
+		Username: c.Params("owner"),
+		Repo:     c.Params("repo"),
+	}
+	if err := validateRequest(req); err != nil {
+		return req, err
+	}
+
+	repoURL := fmt.Sprintf("https://github.com/%s/%s.git", req.Username, req.Repo)
+	if err := git.ValidateRepoURL(repoURL); err != nil {
+		return req, err
+	}
+
+	return req, nil
 }
 
 func validateRequest(req AnalyzeRequest) error {
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	database "github.com/immatheus/gitback/databases"
+	"github.com/immatheus/gitback/storage"
+)
+
+func TestShortHashes(t *testing.T) {
+	commits := []database.CommitStats{
+		{Hash: "0123456789abcdef0123456789abcdef01234567", Author: "a", Added: 3},
+		{Hash: "abc", Author: "b"},
+	}
+
+	short := shortHashes(commits)
+	if short[0].Hash != "0123456" || short[1].Hash != "abc" {
+		t.Errorf("shortHashes returned %q and %q, want 0123456 and abc", short[0].Hash, short[1].Hash)
+	}
+	if short[0].Author != "a" || short[0].Added != 3 {
+		t.Errorf("shortHashes changed other fields: %+v", short[0])
+	}
+	if commits[0].Hash != "0123456789abcdef0123456789abcdef01234567" {
+		t.Errorf("shortHashes modified its input, the commits table needs the full hash")
+	}
+}
+
+// TestRunAnalysisJoinsRunningCall waits for an analysis of the same repo already in progress
+func TestRunAnalysisJoinsRunningCall(t *testing.T) {
+	key := storage.CacheKey("octo", "hello")
+	running := &analysisCall{done: make(chan struct{})}
+	analyzing.Store(key, running)
+	t.Cleanup(func() { analyzing.Delete(key) })
+
+	type result struct {
+		stored storedAnalysis
+		err    error
+	}
+	results := make(chan result)
+	go func() {
+		// Cache keys ignore case, so this is the same repo
+		_, stored, err := runAnalysis("Octo", "Hello", false)
+		results <- result{stored, err}
+	}()
+
+	select {
+	case <-results:
+		t.Fatal("runAnalysis returned before the running analysis finished")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	running.stored = storedAnalysis{commits: []database.CommitStats{{Hash: "abc1234"}}, headSHA: "abc"}
+	running.err = errRepoNotFound
+	close(running.done)
+
+	got := <-results
+	if got.stored.headSHA != "abc" || len(got.stored.commits) != 1 || !errors.Is(got.err, errRepoNotFound) {
+		t.Errorf("runAnalysis returned %+v, %v, want the running analysis's result", got.stored, got.err)
+	}
+}
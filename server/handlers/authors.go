@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"log"
+	"net/url"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/immatheus/gitback/analysis"
+	database "github.com/immatheus/gitback/databases"
+	"github.com/immatheus/gitback/middleware"
+)
+
+// GetAuthor returns an author's contributions across every analyzed repository. The identity is a commit
+// email, an author name or a GitHub username, matched through GitHub's noreply address
+func GetAuthor(c *fiber.Ctx) error {
+	identity, err := url.PathUnescape(c.Params("identity"))
+	identity = strings.TrimSpace(identity)
+	if err != nil || identity == "" || len(identity) > 255 {
+		return middleware.ValidationError(c, "identity must be an email, author name or GitHub username")
+	}
+
+	commits, err := database.GetAuthorCommits(identity)
+	if err != nil {
+		log.Printf("Failed to get commits of author %q: %v", identity, err)
+		return middleware.InternalError(c, "Failed to fetch author")
+	}
+	if len(commits) == 0 {
+		return middleware.NotFoundError(c, "No commits found for this author")
+	}
+
+	return c.JSON(fiber.Map{
+		"identity": identity,
+		"profile":  analysis.AnalyzeAuthor(commits),
+		// Only the most recent commits were loaded, older activity is missing from the profile
+		"truncated": len(commits) >= database.MaxAuthorCommits,
+	})
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/immatheus/gitback/analysis"
+	"github.com/immatheus/gitback/git"
+	"github.com/immatheus/gitback/middleware"
+)
+
+// GetCodeOwners suggests a CODEOWNERS file from recent per-path history and flags stale existing owners
+func GetCodeOwners(c *fiber.Ctx) error {
+	req, err := repoFromParams(c)
+	if err != nil {
+		return middleware.ValidationError(c, err.Error())
+	}
+
+	opts := analysis.CodeOwnersOptions{
+		Depth:        c.QueryInt("depth", 2),
+		MaxOwners:    c.QueryInt("owners", 3),
+		HalfLifeDays: float64(c.QueryInt("halfLifeDays", 90)),
+		StaleMonths:  c.QueryInt("staleMonths", 6),
+		Now:          time.Now(),
+	}
+	lookbackMonths := c.QueryInt("lookbackMonths", 24)
+
+	if opts.Depth < 0 || opts.Depth > 10 {
+		return middleware.ValidationError(c, "depth must be between 0 and 10")
+	}
+	if opts.MaxOwners < 1 || opts.MaxOwners > 20 {
+		return middleware.ValidationError(c, "owners must be between 1 and 20")
+	}
+	if opts.HalfLifeDays < 0 || opts.StaleMonths < 1 || lookbackMonths < 1 {
+		return middleware.ValidationError(c, "halfLifeDays, staleMonths and lookbackMonths must be positive")
+	}
+	if lookbackMonths < opts.StaleMonths {
+		lookbackMonths = opts.StaleMonths
+	}
+
+	repoURL := fmt.Sprintf("https://github.com/%s/%s.git", req.Username, req.Repo)
+
+	repo, err := git.CloneRepository(repoURL)
+	if err != nil {
+		if isNotFoundError(err) {
+			return middleware.NotFoundError(c, "Repository not found")
+		}
+		log.Printf("Failed to clone repository: %s - Error: %v", repoURL, err)
+		return middleware.InternalError(c, "Failed to clone repository")
+	}
+	defer repo.Cleanup()
+
+	changes, err := repo.AnalyzeFileChanges(opts.Now.AddDate(0, -lookbackMonths, 0))
+	if err != nil {
+		log.Printf("Failed to analyze file changes for %s: %v", repoURL, err)
+		return middleware.InternalError(c, "Failed to analyze repository")
+	}
+
+	var existingPath string
+	var existing []byte
+	for _, location := range analysis.CodeOwnersLocations {
+		if data, err := repo.ReadFile(location); err == nil {
+			existingPath = location
+			existing = data
+			break
+		}
+	}
+
+	report := analysis.SuggestCodeOwners(changes, existingPath, existing, opts)
+
+	log.Printf("CODEOWNERS suggestion for %s: %d directories from %d file changes, %d stale owners",
+		repoURL, len(report.Directories), len(changes), len(report.StaleOwners))
+
+	return c.JSON(report)
+}
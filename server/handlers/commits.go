@@ -0,0 +1,308 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	database "github.com/immatheus/gitback/databases"
+	"github.com/immatheus/gitback/middleware"
+)
+
+const (
+	defaultCommitsPageSize = 100
+	maxCommitsPageSize     = 1000
+)
+
+// CommitsPage is one page of a repository's filtered commits
+type CommitsPage struct {
+	Commits    []database.CommitStats `json:"commits"`
+	Total      int                    `json:"total"` // commits matching the filters, across all pages
+	NextCursor string                 `json:"nextCursor,omitempty"`
+}
+
+// commitsQuery holds the filters, sort and page requested from the commits endpoint
+type commitsQuery struct {
+	author   string
+	since    int64
+	until    int64
+	minLines int
+	message  string
+	sortBy   string
+	desc     bool
+	limit    int
+	after    *database.CommitStats // the last commit of the previous page, nil for the first page
+}
+
+// GetCommits returns a page of a repository's commits from its stored analysis
+func GetCommits(c *fiber.Ctx) error {
+	req, err := repoFromParams(c)
+	if err != nil {
+		return middleware.ValidationError(c, err.Error())
+	}
+
+	query, err := parseCommitsQuery(c)
+	if err != nil {
+		return middleware.ValidationError(c, err.Error())
+	}
+
+	stored, err := loadAnalysis(req.Username, req.Repo, true)
+	if err != nil {
+		return analysisError(c, err)
+	}
+
+	page := query.page(stored.commits)
+	body := map[string]interface{}{
+		"commits": page.Commits,
+		"total":   page.Total,
+	}
+	if page.NextCursor != "" {
+		body["nextCursor"] = page.NextCursor
+	}
+
+	return sendNegotiated(c, body)
+}
+
+func parseCommitsQuery(c *fiber.Ctx) (commitsQuery, error) {
+	query := commitsQuery{
+		author:   strings.ToLower(c.Query("author")),
+		minLines: c.QueryInt("minLines", 0),
+		message:  strings.ToLower(c.Query("q")),
+		sortBy:   c.Query("sort", "date"),
+		desc:     c.Query("order", "desc") == "desc",
+		limit:    c.QueryInt("limit", defaultCommitsPageSize),
+	}
+
+	if query.sortBy != "date" && query.sortBy != "size" {
+		return query, fmt.Errorf("sort must be one of: date, size")
+	}
+	if order := c.Query("order", "desc"); order != "asc" && order != "desc" {
+		return query, fmt.Errorf("order must be one of: asc, desc")
+	}
+	if query.limit < 1 || query.limit > maxCommitsPageSize {
+		return query, fmt.Errorf("limit must be between 1 and %d", maxCommitsPageSize)
+	}
+
+	var err error
+	if query.since, err = parseTimeParam(c.Query("since"), false); err != nil {
+		return query, fmt.Errorf("since: %w", err)
+	}
+	if query.until, err = parseTimeParam(c.Query("until"), true); err != nil {
+		return query, fmt.Errorf("until: %w", err)
+	}
+
+	if cursor := c.Query("cursor"); cursor != "" {
+		if query.after, err = query.decodeCursor(cursor); err != nil {
+			return query, fmt.Errorf("invalid cursor")
+		}
+	}
+
+	return query, nil
+}
+
+// page filters, sorts and slices commits. Cursors hold the sort key of the last commit of a page, so the
+// next page resumes after it even when the stored analysis was refreshed in between
+func (q commitsQuery) page(commits []database.CommitStats) CommitsPage {
+	matching := make([]database.CommitStats, 0, len(commits))
+	for _, commit := range commits {
+		if q.matches(commit) {
+			matching = append(matching, commit)
+		}
+	}
+
+	sort.SliceStable(matching, func(i, j int) bool {
+		return q.before(matching[i], matching[j])
+	})
+
+	page := CommitsPage{
+		Commits: []database.CommitStats{},
+		Total:   len(matching),
+	}
+
+	start := 0
+	if q.after != nil {
+		start = sort.Search(len(matching), func(i int) bool {
+			return q.before(*q.after, matching[i])
+		})
+	}
+	if start >= len(matching) {
+		return page
+	}
+
+	end := min(start+q.limit, len(matching))
+	page.Commits = matching[start:end]
+	if end < len(matching) {
+		page.NextCursor = encodeCursor(q.sortKey(matching[end-1]))
+	}
+
+	return page
+}
+
+// before reports whether a comes before b in the requested order. Hashes break ties, so no two commits
+// compare equal
+func (q commitsQuery) before(a, b database.CommitStats) bool {
+	if q.desc {
+		a, b = b, a
+	}
+	if q.sortBy == "size" && a.Added+a.Removed != b.Added+b.Removed {
+		return a.Added+a.Removed < b.Added+b.Removed
+	}
+	if a.Date != b.Date {
+		return a.Date < b.Date
+	}
+	return a.Hash < b.Hash
+}
+
+// sortKey is what a cursor keeps of a commit: its size when sorting by size, then its date and hash
+func (q commitsQuery) sortKey(commit database.CommitStats) []interface{} {
+	key := []interface{}{commit.Date, commit.Hash}
+	if q.sortBy == "size" {
+		key = append([]interface{}{int64(commit.Added + commit.Removed)}, key...)
+	}
+	return key
+}
+
+// decodeCursor turns a cursor back into a commit that sorts where the last commit of the previous page did
+func (q commitsQuery) decodeCursor(cursor string) (*database.CommitStats, error) {
+	key, err := decodeCursor(cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	var after database.CommitStats
+	if q.sortBy == "size" {
+		if len(key) == 0 {
+			return nil, errMalformedCursor
+		}
+		size, ok := key[0].(int64)
+		if !ok {
+			return nil, errMalformedCursor
+		}
+		after.Added = int(size)
+		key = key[1:]
+	}
+
+	if len(key) != 2 {
+		return nil, errMalformedCursor
+	}
+	date, ok := key[0].(int64)
+	hash, hashOk := key[1].(string)
+	if !ok || !hashOk {
+		return nil, errMalformedCursor
+	}
+	after.Date, after.Hash = date, hash
+	return &after, nil
+}
+
+func (q commitsQuery) matches(commit database.CommitStats) bool {
+	if q.author != "" && strings.ToLower(commit.Author) != q.author {
+		return false
+	}
+	if q.since != 0 && commit.Date < q.since {
+		return false
+	}
+	if q.until != 0 && commit.Date > q.until {
+		return false
+	}
+	if commit.Added+commit.Removed < q.minLines {
+		return false
+	}
+	if q.message != "" && !strings.Contains(strings.ToLower(commit.Message), q.message) {
+		return false
+	}
+	return true
+}
+
+// parseTimeParam accepts unix seconds, RFC3339 or a YYYY-MM-DD date, returning 0 for an empty value. A
+// date is its first second, or its last one with endOfDay so that an inclusive bound covers the whole day
+func parseTimeParam(value string, endOfDay bool) (int64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return seconds, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.Unix(), nil
+	}
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		if endOfDay {
+			return t.AddDate(0, 0, 1).Unix() - 1, nil
+		}
+		return t.Unix(), nil
+	}
+	return 0, fmt.Errorf("expected unix seconds, RFC3339 or YYYY-MM-DD")
+}
+
+var errMalformedCursor = errors.New("malformed cursor")
+
+// encodeCursor packs the sort key of the last item of a page into an opaque cursor. The values keep their
+// type, they are int64, float64, bool, string or time.Time as read from the database
+func encodeCursor(key []interface{}) string {
+	parts := make([]string, len(key))
+	for i, value := range key {
+		switch v := value.(type) {
+		case int64:
+			parts[i] = "i" + strconv.FormatInt(v, 10)
+		case float64:
+			parts[i] = "f" + strconv.FormatFloat(v, 'g', -1, 64)
+		case bool:
+			parts[i] = "b" + strconv.FormatBool(v)
+		case time.Time:
+			parts[i] = "t" + v.Format(time.RFC3339Nano)
+		case []byte:
+			parts[i] = "s" + string(v)
+		default:
+			parts[i] = "s" + fmt.Sprint(v)
+		}
+	}
+
+	data, _ := json.Marshal(parts)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor reads the sort key packed by encodeCursor
+func decodeCursor(cursor string) ([]interface{}, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	var parts []string
+	if err := json.Unmarshal(data, &parts); err != nil || len(parts) == 0 {
+		return nil, errMalformedCursor
+	}
+
+	key := make([]interface{}, len(parts))
+	for i, part := range parts {
+		if part == "" {
+			return nil, errMalformedCursor
+		}
+
+		value := part[1:]
+		switch part[0] {
+		case 'i':
+			key[i], err = strconv.ParseInt(value, 10, 64)
+		case 'f':
+			key[i], err = strconv.ParseFloat(value, 64)
+		case 'b':
+			key[i], err = strconv.ParseBool(value)
+		case 't':
+			key[i], err = time.Parse(time.RFC3339Nano, value)
+		case 's':
+			key[i] = value
+		default:
+			err = errMalformedCursor
+		}
+		if err != nil {
+			return nil, errMalformedCursor
+		}
+	}
+	return key, nil
+}
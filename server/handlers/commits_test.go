@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	database "github.com/immatheus/gitback/databases"
+)
+
+func commitHashes(commits []database.CommitStats) []string {
+	hashes := make([]string, len(commits))
+	for i, commit := range commits {
+		hashes[i] = commit.Hash
+	}
+	return hashes
+}
+
+// walkCommits pages through commits with q, reading the pages after the first from next instead
+func walkCommits(t *testing.T, q commitsQuery, first, next []database.CommitStats) []string {
+	t.Helper()
+
+	var walked []string
+	commits := first
+	for pages := 0; pages < 10; pages++ {
+		page := q.page(commits)
+		walked = append(walked, commitHashes(page.Commits)...)
+		if page.NextCursor == "" {
+			return walked
+		}
+
+		after, err := q.decodeCursor(page.NextCursor)
+		if err != nil {
+			t.Fatalf("decodeCursor(%q) failed: %v", page.NextCursor, err)
+		}
+		q.after = after
+		commits = next
+	}
+	t.Fatalf("still paging after 10 pages: %v", walked)
+	return nil
+}
+
+func TestCommitsPages(t *testing.T) {
+	commits := []database.CommitStats{
+		{Hash: "a", Date: 100, Added: 1},
+		{Hash: "b", Date: 300, Added: 50},
+		{Hash: "c", Date: 200, Added: 5},
+		{Hash: "d", Date: 200, Added: 5},
+		{Hash: "e", Date: 400, Added: 2, Removed: 3},
+	}
+
+	tests := []struct {
+		name  string
+		query commitsQuery
+		want  []string
+	}{
+		{"date desc", commitsQuery{sortBy: "date", desc: true, limit: 2}, []string{"e", "b", "d", "c", "a"}},
+		{"date asc", commitsQuery{sortBy: "date", limit: 2}, []string{"a", "c", "d", "b", "e"}},
+		{"size desc", commitsQuery{sortBy: "size", desc: true, limit: 2}, []string{"b", "e", "d", "c", "a"}},
+		{"size asc", commitsQuery{sortBy: "size", limit: 3}, []string{"a", "c", "d", "e", "b"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := walkCommits(t, test.query, commits, commits); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("pages returned %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+// TestCommitsPagesAcrossRefresh reads the second page from an analysis refreshed after the first
+func TestCommitsPagesAcrossRefresh(t *testing.T) {
+	before := []database.CommitStats{
+		{Hash: "a", Date: 100},
+		{Hash: "b", Date: 200},
+		{Hash: "c", Date: 300},
+		{Hash: "d", Date: 400},
+	}
+	// Two new commits on top, they'd shift an offset by two
+	after := append([]database.CommitStats{{Hash: "f", Date: 600}, {Hash: "e", Date: 500}}, before...)
+
+	got := walkCommits(t, commitsQuery{sortBy: "date", desc: true, limit: 2}, before, after)
+	if want := []string{"d", "c", "b", "a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("pages returned %v, want %v", got, want)
+	}
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	key := []interface{}{int64(-42), 0.25, true, "a/b", time.Date(2024, 3, 1, 12, 30, 0, 5, time.UTC)}
+
+	got, err := decodeCursor(encodeCursor(key))
+	if err != nil {
+		t.Fatalf("decodeCursor failed: %v", err)
+	}
+	if !reflect.DeepEqual(got, key) {
+		t.Errorf("round trip returned %#v, want %#v", got, key)
+	}
+
+	for _, cursor := range []string{"", "!!", encodeCursor(nil), "WyJ4MSJd" /* ["x1"] */} {
+		if _, err := decodeCursor(cursor); err == nil {
+			t.Errorf("decodeCursor(%q) succeeded", cursor)
+		}
+	}
+
+	// A date cursor is too short for the size sort
+	dateCursor := encodeCursor(commitsQuery{sortBy: "date"}.sortKey(database.CommitStats{Hash: "a", Date: 1}))
+	if _, err := (commitsQuery{sortBy: "size"}).decodeCursor(dateCursor); err == nil {
+		t.Error("the size sort accepted a date cursor")
+	}
+}
+
+func TestCommitsUntilDate(t *testing.T) {
+	march1 := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	commits := []database.CommitStats{
+		{Hash: "feb", Date: march1.Add(-time.Second).Unix()},
+		{Hash: "morning", Date: march1.Unix()},
+		{Hash: "night", Date: march1.Add(24*time.Hour - time.Second).Unix()},
+		{Hash: "mar2", Date: march1.Add(24 * time.Hour).Unix()},
+	}
+
+	since, err := parseTimeParam("2024-03-01", false)
+	if err != nil {
+		t.Fatalf("parseTimeParam failed: %v", err)
+	}
+	until, err := parseTimeParam("2024-03-01", true)
+	if err != nil {
+		t.Fatalf("parseTimeParam failed: %v", err)
+	}
+
+	page := commitsQuery{since: since, until: until, sortBy: "date", limit: 10}.page(commits)
+	if got, want := commitHashes(page.Commits), []string{"morning", "night"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("since and until 2024-03-01 returned %v, want %v", got, want)
+	}
+
+	// Exact times are kept as they are
+	if exact, _ := parseTimeParam("2024-03-01T00:00:00Z", true); exact != march1.Unix() {
+		t.Errorf("until an RFC3339 time = %d, want %d", exact, march1.Unix())
+	}
+}
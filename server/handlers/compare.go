@@ -0,0 +1,254 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/immatheus/gitback/analysis"
+	"github.com/immatheus/gitback/git"
+	"github.com/immatheus/gitback/middleware"
+)
+
+const (
+	maxCompareRepos = 5
+	// compareJobRetention is how long a finished comparison is kept for the client polling it
+	compareJobRetention = 10 * time.Minute
+)
+
+// compareJob analyzes the repos of a comparison in the background when some have no cached analysis,
+// polled through CompareRepos
+type compareJob struct {
+	total    int
+	analyzed atomic.Int32
+
+	mu         sync.Mutex
+	finishedAt time.Time
+	inputs     []analysis.CompareInput
+	failedRepo string // the first repo that couldn't be analyzed, err says why
+	err        error
+}
+
+// compareJobs holds the running and recently finished comparisons by their lowercased repo list
+var compareJobs sync.Map
+
+// CompareRepos puts the repositories in ?repos=owner/repo,owner/repo side by side. When they all have a
+// cached analysis the comparison is returned right away. Otherwise the first request starts a background
+// job analyzing them and gets 202 with its progress, the same URL is polled until it's done
+func CompareRepos(c *fiber.Ctx) error {
+	repos, err := parseCompareRepos(c.Query("repos"))
+	if err != nil {
+		return middleware.ValidationError(c, err.Error())
+	}
+	key := compareJobKey(repos)
+
+	if job, ok := currentCompareJob(key); ok {
+		if !job.finished() {
+			return compareProgress(c, job)
+		}
+
+		job.mu.Lock()
+		inputs, failedRepo, err := job.inputs, job.failedRepo, job.err
+		job.mu.Unlock()
+		if err != nil {
+			return compareError(c, failedRepo, err)
+		}
+		return c.JSON(analysis.CompareRepos(inputs, time.Now()))
+	}
+
+	if inputs, ok := cachedCompareInputs(repos); ok {
+		return c.JSON(analysis.CompareRepos(inputs, time.Now()))
+	}
+
+	return compareProgress(c, startCompareJob(key, repos))
+}
+
+// cachedCompareInputs returns the comparison inputs when every repo has a cached analysis that can be served
+func cachedCompareInputs(repos []AnalyzeRequest) ([]analysis.CompareInput, bool) {
+	inputs := make([]analysis.CompareInput, len(repos))
+	cached := make([]bool, len(repos))
+
+	var wg sync.WaitGroup
+	for i, repo := range repos {
+		wg.Add(1)
+		go func(i int, repo AnalyzeRequest) {
+			defer wg.Done()
+			// HEAD is checked with ls-remote, which is worth doing in parallel
+			stored, ok := cachedAnalysis(repo.Username, repo.Repo)
+			inputs[i] = analysis.CompareInput{
+				Username: repo.Username,
+				RepoName: repo.Repo,
+				Commits:  stored.commits,
+			}
+			cached[i] = ok
+		}(i, repo)
+	}
+	wg.Wait()
+
+	for _, ok := range cached {
+		if !ok {
+			return nil, false
+		}
+	}
+	return inputs, true
+}
+
+func compareJobKey(repos []AnalyzeRequest) string {
+	names := make([]string, len(repos))
+	for i, repo := range repos {
+		names[i] = strings.ToLower(repo.Username + "/" + repo.Repo)
+	}
+	return strings.Join(names, ",")
+}
+
+func (j *compareJob) finished() bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return !j.finishedAt.IsZero()
+}
+
+// currentCompareJob returns the running or recently finished job of a comparison, dropping it once it's too
+// old to hand out
+func currentCompareJob(key string) (*compareJob, bool) {
+	value, ok := compareJobs.Load(key)
+	if !ok {
+		return nil, false
+	}
+	job := value.(*compareJob)
+
+	job.mu.Lock()
+	finishedAt := job.finishedAt
+	job.mu.Unlock()
+
+	if !finishedAt.IsZero() && time.Since(finishedAt) >= compareJobRetention {
+		compareJobs.CompareAndDelete(key, job)
+		return nil, false
+	}
+	return job, true
+}
+
+// startCompareJob analyzes the repos of a comparison in the background, joining the comparison's job if one
+// is already running
+func startCompareJob(key string, repos []AnalyzeRequest) *compareJob {
+	job := &compareJob{total: len(repos)}
+	for {
+		value, loaded := compareJobs.LoadOrStore(key, job)
+		if !loaded {
+			break
+		}
+		existing := value.(*compareJob)
+		if !existing.finished() {
+			return existing
+		}
+		compareJobs.CompareAndDelete(key, existing)
+	}
+	go runCompareJob(repos, job)
+
+	return job
+}
+
+// runCompareJob loads or analyzes every repo of a comparison, cached ones come straight from the cache
+func runCompareJob(repos []AnalyzeRequest, job *compareJob) {
+	inputs := make([]analysis.CompareInput, len(repos))
+	errs := make([]error, len(repos))
+
+	slots := analysisSlots()
+	var wg sync.WaitGroup
+	for i, repo := range repos {
+		wg.Add(1)
+		go func(i int, repo AnalyzeRequest) {
+			defer wg.Done()
+
+			// Cached repos are read straight away, clones wait for a slot shared with every other job
+			stored, ok := cachedAnalysis(repo.Username, repo.Repo)
+			var err error
+			if !ok {
+				slots <- struct{}{}
+				stored, err = loadAnalysis(repo.Username, repo.Repo, true)
+				<-slots
+			}
+			inputs[i] = analysis.CompareInput{
+				Username: repo.Username,
+				RepoName: repo.Repo,
+				Commits:  stored.commits,
+			}
+			errs[i] = err
+			if err == nil {
+				job.analyzed.Add(1)
+			}
+		}(i, repo)
+	}
+	wg.Wait()
+
+	job.mu.Lock()
+	defer job.mu.Unlock()
+	job.finishedAt = time.Now()
+	for i, err := range errs {
+		if err != nil {
+			job.failedRepo = repos[i].Username + "/" + repos[i].Repo
+			job.err = err
+			log.Printf("Failed to load %s for comparison: %v", job.failedRepo, err)
+			return
+		}
+	}
+	job.inputs = inputs
+}
+
+func compareProgress(c *fiber.Ctx, job *compareJob) error {
+	c.Set("Retry-After", "5")
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"status":   "running",
+		"total":    job.total,
+		"analyzed": job.analyzed.Load(),
+	})
+}
+
+// compareError turns the failure to load one repo of a comparison into the matching error response
+func compareError(c *fiber.Ctx, name string, err error) error {
+	if errors.Is(err, errRepoNotFound) {
+		return middleware.NotFoundError(c, fmt.Sprintf("Repository %s not found", name))
+	}
+	return middleware.InternalError(c, fmt.Sprintf("Failed to analyze repository %s", name))
+}
+
+// parseCompareRepos reads a comma separated list of owner/repo, dropping duplicates
+func parseCompareRepos(value string) ([]AnalyzeRequest, error) {
+	var repos []AnalyzeRequest
+	seen := make(map[string]bool)
+
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		owner, repo, ok := strings.Cut(name, "/")
+		if !ok || strings.Contains(repo, "/") {
+			return nil, fmt.Errorf("repos must be a comma separated list of owner/repo, got %q", name)
+		}
+		req := AnalyzeRequest{Username: owner, Repo: repo}
+		if err := validateRequest(req); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		if err := git.ValidateRepoURL(fmt.Sprintf("https://github.com/%s/%s.git", owner, repo)); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+
+		key := strings.ToLower(name)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		repos = append(repos, req)
+	}
+
+	if len(repos) < 2 || len(repos) > maxCompareRepos {
+		return nil, fmt.Errorf("repos must list between 2 and %d different repositories", maxCompareRepos)
+	}
+	return repos, nil
+}
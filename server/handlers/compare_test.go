@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	database "github.com/immatheus/gitback/databases"
+	"github.com/immatheus/gitback/storage"
+)
+
+// newCompareApp serves CompareRepos from a memory cache holding analyses of octo/cached and octo/other
+func newCompareApp(t *testing.T) *fiber.App {
+	t.Helper()
+
+	t.Setenv("CACHE_BACKEND", "memory")
+	if err := storage.Init(); err != nil {
+		t.Fatalf("storage.Init failed: %v", err)
+	}
+	t.Cleanup(func() { storage.Close() })
+
+	commits := []database.CommitStats{
+		{Hash: "abc1234", Author: "mona", Date: time.Now().AddDate(0, -2, 0).Unix(), Added: 10},
+		{Hash: "def5678", Author: "hubot", Date: time.Now().AddDate(0, -1, 0).Unix(), Added: 5, Removed: 2},
+	}
+	for _, repo := range []string{"cached", "other"} {
+		// Without a head_sha freshness goes by age, so nothing is checked against GitHub
+		if err := storage.StoreInCache("octo", repo, "", map[string]interface{}{"commits": commits}); err != nil {
+			t.Fatalf("StoreInCache failed: %v", err)
+		}
+	}
+
+	app := fiber.New()
+	app.Get("/api/compare", CompareRepos)
+	return app
+}
+
+func getCompare(t *testing.T, app *fiber.App, repos string) (int, map[string]interface{}) {
+	t.Helper()
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/api/compare?repos="+repos, nil), -1)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	data, _ := io.ReadAll(resp.Body)
+	var body map[string]interface{}
+	if err := json.Unmarshal(data, &body); err != nil {
+		t.Fatalf("response is not JSON: %s", data)
+	}
+	return resp.StatusCode, body
+}
+
+func TestCompareReposCached(t *testing.T) {
+	app := newCompareApp(t)
+
+	status, body := getCompare(t, app, "octo/cached,octo/other")
+	if status != fiber.StatusOK {
+		t.Fatalf("status = %d, want 200: %v", status, body)
+	}
+	repos, _ := body["repos"].([]interface{})
+	if len(repos) != 2 {
+		t.Fatalf("compared %d repos, want 2: %v", len(repos), body)
+	}
+	if first := repos[0].(map[string]interface{}); first["repoName"] != "cached" || first["totalCommits"] != 2.0 {
+		t.Errorf("first repo = %v, want cached with 2 commits", first)
+	}
+}
+
+func TestCompareReposJob(t *testing.T) {
+	app := newCompareApp(t)
+	key := "octo/cached,octo/cold"
+	t.Cleanup(func() { compareJobs.Delete(key) })
+
+	// A comparison with a cold repo is answered with the progress of its job
+	running := &compareJob{total: 2}
+	running.analyzed.Add(1)
+	compareJobs.Store(key, running)
+
+	status, body := getCompare(t, app, "octo/cached,octo/cold")
+	if status != fiber.StatusAccepted {
+		t.Fatalf("status = %d, want 202: %v", status, body)
+	}
+	if body["status"] != "running" || body["total"] != 2.0 || body["analyzed"] != 1.0 {
+		t.Errorf("progress = %v, want running with 1 of 2 analyzed", body)
+	}
+
+	// Once it finishes, the next poll gets its result
+	failed := &compareJob{total: 2, finishedAt: time.Now(), failedRepo: "octo/cold", err: errRepoNotFound}
+	compareJobs.Store(key, failed)
+
+	status, body = getCompare(t, app, "octo/cached,octo/cold")
+	if status != fiber.StatusNotFound {
+		t.Errorf("status = %d, want 404 for a repo that doesn't exist: %v", status, body)
+	}
+
+	// Finished jobs are dropped after a while, so the cache is checked again
+	failed.finishedAt = time.Now().Add(-compareJobRetention)
+	if _, ok := currentCompareJob(key); ok {
+		t.Error("a job finished longer ago than compareJobRetention is still handed out")
+	}
+}
+
+// TestCompareJobCachedReposSkipSlots finishes a job over cached repos while every analysis slot is taken
+func TestCompareJobCachedReposSkipSlots(t *testing.T) {
+	newCompareApp(t)
+
+	slots := analysisSlots()
+	for i := 0; i < cap(slots); i++ {
+		slots <- struct{}{}
+	}
+	t.Cleanup(func() {
+		for i := 0; i < cap(slots); i++ {
+			<-slots
+		}
+	})
+
+	job := &compareJob{total: 2}
+	done := make(chan struct{})
+	go func() {
+		runCompareJob([]AnalyzeRequest{{Username: "octo", Repo: "cached"}, {Username: "octo", Repo: "other"}}, job)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("compare job of cached repos waited for an analysis slot")
+	}
+	if job.err != nil || len(job.inputs) != 2 || job.analyzed.Load() != 2 {
+		t.Errorf("job finished with %v, %d inputs and %d analyzed, want both repos", job.err, len(job.inputs), job.analyzed.Load())
+	}
+}
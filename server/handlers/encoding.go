@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/immatheus/gitback/columnar"
+	database "github.com/immatheus/gitback/databases"
+	"github.com/immatheus/gitback/middleware"
+	"github.com/immatheus/gitback/storage"
+)
+
+// sendNegotiated writes a payload holding commits under the "commits" key in the encoding asked for by the
+// Accept header: plain JSON by default, or with columnar commits as JSON or MessagePack
+func sendNegotiated(c *fiber.Ctx, body map[string]interface{}) error {
+	contentType := acceptedEncoding(c)
+	if contentType == fiber.MIMEApplicationJSON {
+		return c.JSON(body)
+	}
+
+	body, err := withColumnarCommits(body)
+	if err != nil {
+		log.Printf("Failed to encode commits as columns: %v", err)
+		return middleware.InternalError(c, "Failed to encode response")
+	}
+
+	var data []byte
+	if contentType == columnar.ContentTypeMsgpack {
+		data, err = columnar.MarshalMsgpack(body)
+	} else {
+		data, err = json.Marshal(body)
+	}
+	if err != nil {
+		log.Printf("Failed to encode %s response: %v", contentType, err)
+		return middleware.InternalError(c, "Failed to encode response")
+	}
+
+	c.Set(fiber.HeaderContentType, contentType)
+	return c.Send(data)
+}
+
+// sendCachedAnalysis serves a cached analysis with its stale flag and cachedAt time. Plain JSON requests
+// for the full analysis get the stored bytes with the two fields spliced in, anything else has to be decoded
+func sendCachedAnalysis(c *fiber.Ctx, cached *storage.CachedObject, omitCommits bool) error {
+	cachedAt := cached.CachedAt.UTC().Format(time.RFC3339)
+
+	if !omitCommits && acceptedEncoding(c) == fiber.MIMEApplicationJSON {
+		prefix := fmt.Sprintf(`{"stale":%t,"cachedAt":%q`, cached.Stale, cachedAt)
+
+		// Splice the fields in at the start of the stored object rather than re-encoding all of it
+		body := bytes.TrimSpace(cached.Data)
+		if len(body) < 2 || body[0] != '{' {
+			log.Printf("Cached analysis is not a JSON object")
+			return middleware.InternalError(c, "Failed to read cached analysis")
+		}
+		rest := bytes.TrimSpace(body[1:])
+		if rest[0] != '}' {
+			prefix += ","
+		}
+
+		c.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+		return c.Send(append([]byte(prefix), rest...))
+	}
+
+	var body map[string]interface{}
+	if err := json.Unmarshal(cached.Data, &body); err != nil {
+		log.Printf("Failed to unmarshal cached analysis: %v", err)
+		return middleware.InternalError(c, "Failed to read cached analysis")
+	}
+	if omitCommits {
+		delete(body, "commits")
+	}
+	body["stale"] = cached.Stale
+	body["cachedAt"] = cachedAt
+
+	return sendNegotiated(c, body)
+}
+
+// acceptedEncoding picks the response content type from the Accept header, plain JSON unless a columnar
+// encoding is asked for
+func acceptedEncoding(c *fiber.Ctx) string {
+	c.Vary(fiber.HeaderAccept)
+
+	switch contentType := c.Accepts(fiber.MIMEApplicationJSON, columnar.ContentTypeJSON, columnar.ContentTypeMsgpack); contentType {
+	case columnar.ContentTypeJSON, columnar.ContentTypeMsgpack:
+		return contentType
+	default:
+		return fiber.MIMEApplicationJSON
+	}
+}
+
+// withColumnarCommits returns a copy of body with its commits in columnar form
+func withColumnarCommits(body map[string]interface{}) (map[string]interface{}, error) {
+	raw, ok := body["commits"]
+	if !ok || raw == nil {
+		return body, nil
+	}
+
+	commits, ok := raw.([]database.CommitStats)
+	if !ok {
+		// Cached analyses come back as generic JSON values
+		data, err := json.Marshal(raw)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal commits: %w", err)
+		}
+		if err := json.Unmarshal(data, &commits); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal commits: %w", err)
+		}
+	}
+
+	encoded := make(map[string]interface{}, len(body))
+	for k, v := range body {
+		encoded[k] = v
+	}
+	encoded["commits"] = columnar.Encode(commits)
+
+	return encoded, nil
+}
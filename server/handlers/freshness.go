@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/immatheus/gitback/git"
+	"github.com/immatheus/gitback/storage"
+)
+
+// headCheckTTL is how long a remote HEAD lookup is reused, so hot repos don't run ls-remote on every request
+const headCheckTTL = time.Minute
+
+type headCheck struct {
+	sha       string
+	checkedAt time.Time
+}
+
+var (
+	headChecksMu sync.Mutex
+	headChecks   = make(map[string]headCheck)
+)
+
+// remoteHead looks up the remote HEAD of a repository, reusing recent lookups
+func remoteHead(repoURL string) (string, error) {
+	headChecksMu.Lock()
+	check, ok := headChecks[repoURL]
+	headChecksMu.Unlock()
+
+	if ok && time.Since(check.checkedAt) < headCheckTTL {
+		return check.sha, nil
+	}
+
+	sha, err := git.RemoteHead(repoURL)
+	if err != nil {
+		return "", err
+	}
+
+	headChecksMu.Lock()
+	headChecks[repoURL] = headCheck{sha: sha, checkedAt: time.Now()}
+	// Drop old lookups while we're here so the map doesn't grow with every repo ever requested
+	for url, check := range headChecks {
+		if time.Since(check.checkedAt) >= headCheckTTL {
+			delete(headChecks, url)
+		}
+	}
+	headChecksMu.Unlock()
+
+	return sha, nil
+}
+
+// checkFreshness decides if a cached analysis can be served, updating its stale flag. Analyses made at the
+// current remote HEAD are fresh no matter how old, ones made at an older HEAD are stale right away. When
+// the HEAD is unknown, either side, it falls back to the age of the entry
+func checkFreshness(repoURL string, cached *storage.CachedObject) bool {
+	if cached.HeadSHA != "" {
+		head, err := remoteHead(repoURL)
+		if err == nil {
+			if head == cached.HeadSHA {
+				cached.Stale = false
+				return true
+			}
+			cached.Stale = true
+			return !cached.Expired
+		}
+		log.Printf("Failed to check remote HEAD of %s, falling back to cache age: %v", repoURL, err)
+	}
+
+	return !cached.Expired
+}
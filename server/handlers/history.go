@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"log"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	database "github.com/immatheus/gitback/databases"
+	"github.com/immatheus/gitback/middleware"
+)
+
+// GetHistory returns the metrics recorded at each analysis of a repository, oldest first, optionally
+// starting at ?since= (unix seconds, RFC3339 or YYYY-MM-DD)
+func GetHistory(c *fiber.Ctx) error {
+	req, err := repoFromParams(c)
+	if err != nil {
+		return middleware.ValidationError(c, err.Error())
+	}
+
+	since, err := parseTimeParam(c.Query("since"), false)
+	if err != nil {
+		return middleware.ValidationError(c, "since: "+err.Error())
+	}
+
+	repo, err := database.GetRepo(req.Username, req.Repo)
+	if err != nil {
+		log.Printf("Failed to get repo %s/%s: %v", req.Username, req.Repo, err)
+		return middleware.InternalError(c, "Failed to fetch repository history")
+	}
+	if repo == nil {
+		return middleware.NotFoundError(c, "Repository has not been analyzed")
+	}
+
+	snapshots, err := database.GetSnapshots(req.Username, req.Repo, time.Unix(since, 0))
+	if err != nil {
+		log.Printf("Failed to get snapshots for %s/%s: %v", req.Username, req.Repo, err)
+		return middleware.InternalError(c, "Failed to fetch repository history")
+	}
+
+	return c.JSON(fiber.Map{
+		"snapshots": snapshots,
+	})
+}
@@ -0,0 +1,317 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/immatheus/gitback/analysis"
+	"github.com/immatheus/gitback/middleware"
+	"github.com/immatheus/gitback/storage"
+)
+
+const (
+	// maxOrgRepos caps how many repos of an org are analyzed, the most recently pushed ones are kept
+	maxOrgRepos = 100
+	// orgJobRetention is how long a finished job's result is kept for the client polling it
+	orgJobRetention = 10 * time.Minute
+)
+
+var (
+	errOrgNotFound = errors.New("organization or user not found")
+
+	// GitHub logins are alphanumeric with single hyphens, up to 39 characters
+	githubLogin = regexp.MustCompile(`^[A-Za-z0-9](?:[A-Za-z0-9]|-[A-Za-z0-9]){0,38}$`)
+)
+
+// GitHubOrgRepo is a repository as listed by the GitHub API
+type GitHubOrgRepo struct {
+	Name            string `json:"name"`
+	Fork            bool   `json:"fork"`
+	Archived        bool   `json:"archived"`
+	Language        string `json:"language"`
+	StargazersCount int    `json:"stargazers_count"`
+	Size            int    `json:"size"`
+}
+
+// orgJob is an org analysis running in the background, polled through GetOrg
+type orgJob struct {
+	total    int
+	analyzed atomic.Int32
+	failed   atomic.Int32
+
+	mu         sync.Mutex
+	finishedAt time.Time
+	report     *analysis.OrgReport
+}
+
+// orgJobs holds the running and recently finished org analyses by lowercased org name
+var orgJobs sync.Map
+
+// orgResponse is an org report along with how fresh it is, like a cached analysis
+type orgResponse struct {
+	analysis.OrgReport
+	Stale    bool   `json:"stale"`
+	CachedAt string `json:"cachedAt,omitempty"`
+}
+
+// GetOrg returns the combined report of every repository of a GitHub org or user. Analyzing them takes a
+// while, so the first request starts a background job and gets 202 with its progress until it's done. Stale
+// reports are served while a job refreshes them
+func GetOrg(c *fiber.Ctx) error {
+	org := c.Params("org")
+	if !githubLogin.MatchString(org) {
+		return middleware.ValidationError(c, "org must be a valid GitHub organization or user name")
+	}
+	key := strings.ToLower(org)
+
+	if cached, err := storage.GetCachedObject(storage.OrgCacheKey(org)); err != nil {
+		log.Printf("Cache check failed: %v", err)
+	} else if cached != nil && !cached.Expired {
+		var report analysis.OrgReport
+		if err := json.Unmarshal(cached.Data, &report); err != nil {
+			log.Printf("Failed to unmarshal cached org report of %s: %v", org, err)
+		} else {
+			if cached.Stale {
+				refreshOrgInBackground(org)
+			}
+			return c.JSON(orgResponse{
+				OrgReport: report,
+				Stale:     cached.Stale,
+				CachedAt:  cached.CachedAt.UTC().Format(time.RFC3339),
+			})
+		}
+	}
+
+	if job, ok := currentOrgJob(key); ok {
+		if !job.finished() {
+			return orgProgress(c, org, job)
+		}
+		// Served from here in case the report couldn't be cached
+		job.mu.Lock()
+		report := job.report
+		job.mu.Unlock()
+		return c.JSON(orgResponse{OrgReport: *report})
+	}
+
+	job, err := startOrgJob(org)
+	if err != nil {
+		if errors.Is(err, errOrgNotFound) {
+			return middleware.NotFoundError(c, "Organization or user not found")
+		}
+		log.Printf("[ORG] Failed to list repositories of %s: %v", org, err)
+		return middleware.InternalError(c, "Failed to list organization repositories")
+	}
+
+	return orgProgress(c, org, job)
+}
+
+func (j *orgJob) finished() bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return !j.finishedAt.IsZero()
+}
+
+// currentOrgJob returns the running or recently finished job of an org, dropping it once it's too old to hand
+// out
+func currentOrgJob(key string) (*orgJob, bool) {
+	value, ok := orgJobs.Load(key)
+	if !ok {
+		return nil, false
+	}
+	job := value.(*orgJob)
+
+	job.mu.Lock()
+	finishedAt := job.finishedAt
+	job.mu.Unlock()
+
+	if !finishedAt.IsZero() && time.Since(finishedAt) >= orgJobRetention {
+		orgJobs.CompareAndDelete(key, job)
+		return nil, false
+	}
+	return job, true
+}
+
+// startOrgJob lists the repos of an org and analyzes them in the background, joining the org's job if one is
+// already running
+func startOrgJob(org string) (*orgJob, error) {
+	repos, err := fetchOrgRepos(org)
+	if err != nil {
+		return nil, err
+	}
+
+	key := strings.ToLower(org)
+	job := &orgJob{total: len(repos)}
+	for {
+		value, loaded := orgJobs.LoadOrStore(key, job)
+		if !loaded {
+			break
+		}
+		existing := value.(*orgJob)
+		if !existing.finished() {
+			return existing, nil
+		}
+		// The new job supersedes a finished one's report
+		orgJobs.CompareAndDelete(key, existing)
+	}
+	go runOrgAnalysis(org, repos, job)
+
+	return job, nil
+}
+
+// refreshOrgInBackground starts a job to replace a stale org report, at most once at a time per org
+func refreshOrgInBackground(org string) {
+	key := storage.OrgCacheKey(org)
+	if _, running := refreshing.LoadOrStore(key, true); running {
+		return
+	}
+
+	go func() {
+		defer refreshing.Delete(key)
+
+		if job, ok := currentOrgJob(strings.ToLower(org)); ok && !job.finished() {
+			return
+		}
+		log.Printf("[ORG] Refreshing stale report of %s", org)
+		if _, err := startOrgJob(org); err != nil {
+			log.Printf("[ORG] Failed to refresh stale report of %s: %v", org, err)
+		}
+	}()
+}
+
+func orgProgress(c *fiber.Ctx, org string, job *orgJob) error {
+	c.Set("Retry-After", "5")
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"org":      org,
+		"status":   "running",
+		"total":    job.total,
+		"analyzed": job.analyzed.Load(),
+		"failed":   job.failed.Load(),
+	})
+}
+
+// runOrgAnalysis analyzes every repo of an org, a few at a time, and caches the combined report
+func runOrgAnalysis(org string, repos []GitHubOrgRepo, job *orgJob) {
+	start := time.Now()
+	log.Printf("[ORG] Analyzing %d repositories of %s", len(repos), org)
+
+	// Each repo is folded in as soon as it's loaded so at most one analysis per worker is held in memory
+	aggregator := analysis.NewOrgAggregator(org)
+	var mu sync.Mutex
+
+	slots := analysisSlots()
+	var wg sync.WaitGroup
+	for _, repo := range repos {
+		slots <- struct{}{}
+		wg.Add(1)
+		go func(repo GitHubOrgRepo) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			// Views are left alone, sweeping an org isn't anyone looking at these repos
+			stored, err := loadAnalysis(org, repo.Name, false)
+			if err != nil {
+				log.Printf("[ORG] Failed to analyze %s/%s: %v", org, repo.Name, err)
+				mu.Lock()
+				aggregator.Fail(repo.Name)
+				mu.Unlock()
+				job.failed.Add(1)
+				return
+			}
+
+			mu.Lock()
+			aggregator.Add(analysis.OrgRepoInput{
+				Name:     repo.Name,
+				Language: repo.Language,
+				Stars:    repo.StargazersCount,
+				Size:     repo.Size,
+				Commits:  stored.commits,
+			})
+			mu.Unlock()
+			job.analyzed.Add(1)
+		}(repo)
+	}
+	wg.Wait()
+
+	report := aggregator.Report()
+
+	log.Printf("[ORG] Analyzed %d of %d repositories of %s (took %v)",
+		report.TotalRepos, len(repos), org, time.Since(start))
+
+	metadata := map[string]string{
+		"org": org,
+	}
+	if err := storage.StoreCachedJSON(storage.OrgCacheKey(org), report, metadata); err != nil {
+		log.Printf("Failed to store org report in cache for %s: %v", org, err)
+	}
+
+	job.mu.Lock()
+	job.report = &report
+	job.finishedAt = time.Now()
+	job.mu.Unlock()
+}
+
+// fetchOrgRepos lists the repos owned by a GitHub org or user, most recently pushed first, leaving out
+// forks, archived and empty repos
+func fetchOrgRepos(org string) ([]GitHubOrgRepo, error) {
+	const perPage = 100
+
+	var repos []GitHubOrgRepo
+	for page := 1; len(repos) < maxOrgRepos; page++ {
+		// The users endpoint lists an org's repos too
+		url := fmt.Sprintf("https://api.github.com/users/%s/repos?type=owner&sort=pushed&per_page=%d&page=%d", org, perPage, page)
+		req, err := http.NewRequest("GET", url, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		if token := os.Getenv("GITHUB_TOKEN"); token != "" {
+			req.Header.Set("Authorization", "token "+token)
+		}
+		req.Header.Set("Accept", "application/vnd.github.v3+json")
+
+		resp, err := githubClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+
+		if resp.StatusCode == http.StatusNotFound {
+			resp.Body.Close()
+			return nil, errOrgNotFound
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("GitHub API returned status %d", resp.StatusCode)
+		}
+		var listed []GitHubOrgRepo
+		err = json.NewDecoder(resp.Body).Decode(&listed)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, repo := range listed {
+			if repo.Fork || repo.Archived || repo.Size == 0 {
+				continue
+			}
+			repos = append(repos, repo)
+		}
+		if len(listed) < perPage {
+			break
+		}
+	}
+
+	if len(repos) > maxOrgRepos {
+		repos = repos[:maxOrgRepos]
+	}
+	return repos, nil
+}
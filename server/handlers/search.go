@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	database "github.com/immatheus/gitback/databases"
+	"github.com/immatheus/gitback/middleware"
+)
+
+const (
+	defaultSearchLimit = 10
+	maxSearchLimit     = 50
+)
+
+// SearchRepos finds analyzed repos whose owner/repo matches ?q=, for autocomplete. Pages with ?limit= and
+// the ?cursor= from the previous page
+func SearchRepos(c *fiber.Ctx) error {
+	term := strings.TrimSpace(c.Query("q"))
+	if term == "" || len(term) > 100 {
+		return middleware.ValidationError(c, "q must be between 1 and 100 characters")
+	}
+
+	limit := c.QueryInt("limit", defaultSearchLimit)
+	if limit < 1 || limit > maxSearchLimit {
+		return middleware.ValidationError(c, "limit must be between 1 and 50")
+	}
+
+	var after database.Cursor
+	if cursor := c.Query("cursor"); cursor != "" {
+		var err error
+		if after, err = decodeCursor(cursor); err != nil {
+			return middleware.ValidationError(c, "invalid cursor")
+		}
+	}
+
+	page, err := database.SearchRepos(term, limit, after)
+	if errors.Is(err, database.ErrInvalidCursor) {
+		return middleware.ValidationError(c, "invalid cursor")
+	}
+	if err != nil {
+		log.Printf("Failed to search repos for %q: %v", term, err)
+		return middleware.InternalError(c, "Failed to search repositories")
+	}
+
+	body := fiber.Map{
+		"repos": page.Repos,
+		"total": page.Total,
+	}
+	if page.Next != nil {
+		body["nextCursor"] = encodeCursor(page.Next)
+	}
+
+	return c.JSON(body)
+}
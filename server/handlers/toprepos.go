@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	database "github.com/immatheus/gitback/databases"
+	"github.com/immatheus/gitback/middleware"
+)
+
+const maxTopReposPageSize = 100
+
+var topReposSorts = []string{
+	database.TopReposByLines,
+	database.TopReposByStars,
+	database.TopReposByCommits,
+	database.TopReposByViews,
+	database.TopReposByRecent,
+}
+
+// GetTopRepos returns a page of the leaderboard. Accepts ?sort= (lines, stars, commits, views, recent),
+// ?language=, ?minStars=, ?limit= and the ?cursor= from the previous page
+func GetTopRepos(c *fiber.Ctx) error {
+	query, err := parseTopReposQuery(c)
+	if err != nil {
+		return middleware.ValidationError(c, err.Error())
+	}
+
+	page, err := database.GetTopRepos(query)
+	if errors.Is(err, database.ErrInvalidCursor) {
+		return middleware.ValidationError(c, "invalid cursor")
+	}
+	if err != nil {
+		log.Printf("Failed to get top repos: %v", err)
+		return middleware.InternalError(c, "Failed to fetch top repositories")
+	}
+
+	body := fiber.Map{
+		"repos": page.Repos,
+		"total": page.Total,
+	}
+	if page.Next != nil {
+		body["nextCursor"] = encodeCursor(page.Next)
+	}
+
+	return c.JSON(body)
+}
+
+func parseTopReposQuery(c *fiber.Ctx) (database.TopReposQuery, error) {
+	query := database.TopReposQuery{
+		Sort:     c.Query("sort", database.TopReposByLines),
+		Language: c.Query("language"),
+		MinStars: c.QueryInt("minStars", 0),
+		Limit:    c.QueryInt("limit", database.DefaultTopReposLimit),
+	}
+
+	validSort := false
+	for _, sort := range topReposSorts {
+		validSort = validSort || query.Sort == sort
+	}
+	if !validSort {
+		return query, fmt.Errorf("sort must be one of: %s", strings.Join(topReposSorts, ", "))
+	}
+	if query.Limit < 1 || query.Limit > maxTopReposPageSize {
+		return query, fmt.Errorf("limit must be between 1 and %d", maxTopReposPageSize)
+	}
+	if query.MinStars < 0 {
+		return query, fmt.Errorf("minStars must not be negative")
+	}
+	if len(query.Language) > 100 || containsUnsafeChars(query.Language) {
+		return query, fmt.Errorf("invalid language")
+	}
+
+	if cursor := c.Query("cursor"); cursor != "" {
+		after, err := decodeCursor(cursor)
+		if err != nil {
+			return query, fmt.Errorf("invalid cursor")
+		}
+		query.After = after
+	}
+
+	return query, nil
+}
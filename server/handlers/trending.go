@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"log"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/immatheus/gitback/analysis"
+	database "github.com/immatheus/gitback/databases"
+	"github.com/immatheus/gitback/middleware"
+)
+
+const (
+	defaultTrendingLimit = 20
+	maxTrendingLimit     = 100
+)
+
+// GetTrending ranks repos by view growth over ?window= (24h or 7d), recent views weighing more
+func GetTrending(c *fiber.Ctx) error {
+	windowName := c.Query("window", "24h")
+	window, ok := analysis.TrendingWindows[windowName]
+	if !ok {
+		return middleware.ValidationError(c, "window must be one of: 24h, 7d")
+	}
+
+	limit := c.QueryInt("limit", defaultTrendingLimit)
+	if limit < 1 || limit > maxTrendingLimit {
+		return middleware.ValidationError(c, "limit must be between 1 and 100")
+	}
+
+	now := time.Now().UTC()
+	views, err := database.GetDailyViews(now.Add(-2 * window.Length))
+	if err != nil {
+		log.Printf("Failed to get daily views: %v", err)
+		return middleware.InternalError(c, "Failed to fetch trending repositories")
+	}
+
+	repos := analysis.RankTrending(views, window, now)
+	if len(repos) > limit {
+		repos = repos[:limit]
+	}
+
+	return c.JSON(fiber.Map{
+		"window": windowName,
+		"repos":  repos,
+	})
+}
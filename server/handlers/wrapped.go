@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/immatheus/gitback/analysis"
+	"github.com/immatheus/gitback/middleware"
+	"github.com/immatheus/gitback/storage"
+)
+
+// GetWrapped returns the year in review highlights of a repository, computed server side so the
+// client doesn't need every commit
+func GetWrapped(c *fiber.Ctx) error {
+	req, err := repoFromParams(c)
+	if err != nil {
+		return middleware.ValidationError(c, err.Error())
+	}
+
+	year := time.Now().Year()
+	if yearParam := c.Query("year"); yearParam != "" {
+		year, err = strconv.Atoi(yearParam)
+		if err != nil || year < 1970 || year > time.Now().Year() {
+			return middleware.ValidationError(c, "year must be a valid year no later than the current one")
+		}
+	}
+
+	key := storage.WrappedCacheKey(req.Username, req.Repo, year)
+	repoURL := fmt.Sprintf("https://github.com/%s/%s.git", req.Username, req.Repo)
+
+	// A report is only reused while it was made from the analysis of the current HEAD, new commits
+	// invalidate it right away
+	if cached, err := storage.GetCachedObject(key); err != nil {
+		log.Printf("Cache check failed: %v", err)
+	} else if cached != nil && checkFreshness(repoURL, cached) && !cached.Stale {
+		var report analysis.WrappedReport
+		if err := json.Unmarshal(cached.Data, &report); err == nil {
+			return c.JSON(report)
+		}
+		log.Printf("Failed to unmarshal cached wrapped of %s: %v", repoURL, err)
+	}
+
+	stored, err := loadAnalysis(req.Username, req.Repo, true)
+	if err != nil {
+		return analysisError(c, err)
+	}
+
+	report := analysis.AnalyzeWrapped(stored.commits, year)
+
+	go func() {
+		metadata := map[string]string{
+			"username": req.Username,
+			"repo":     req.Repo,
+			"year":     strconv.Itoa(year),
+			"head_sha": stored.headSHA,
+		}
+		if err := storage.StoreCachedJSON(key, report, metadata); err != nil {
+			log.Printf("Failed to store wrapped in cache for %s/%s: %v", req.Username, req.Repo, err)
+		}
+	}()
+
+	return c.JSON(report)
+}
@@ -1,8 +1,10 @@
 package main
 
 import (
+	"fmt"
 	"log"
 	"os"
+	"strconv"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
@@ -20,10 +22,19 @@ import (
 func main() {
 	godotenv.Load() // only for dev, gcp injects this for us
 
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrate(os.Args[2:])
+		return
+	}
+
 	if err := database.Init(os.Getenv("DATABASE_URL")); err != nil {
 		log.Printf("ERROR: Database initialization failed: %v", err)
 		log.Printf("Continuing without database - data will not be persisted")
 	} else {
+		// Serving against a half migrated schema would fail in confusing ways, better not to start
+		if err := database.MigrateUp(); err != nil {
+			log.Fatalf("Database migration failed: %v", err)
+		}
 		log.Printf("Database initialized successfully")
 	}
 	defer database.Close()
@@ -32,7 +43,7 @@ func main() {
 		log.Printf("WARNING: Storage cache initialization failed: %v", err)
 		log.Printf("Continuing without cache - requests will be slower")
 	} else {
-		log.Printf("GCP Storage cache initialized successfully")
+		log.Printf("Storage cache initialized successfully")
 	}
 	defer storage.Close()
 
@@ -89,13 +100,35 @@ func main() {
 			"status":  "healthy",
 			"version": "2.0.0",
 			"time":    time.Now().Unix(),
+			"cache":   storage.Stats(),
 		})
 	})
 
 	// API routes with rate limiting
 	api := app.Group("/api", generalRateLimit)
 	api.Post("/analyze", analyzeRateLimit, handlers.AnalyzeRepo)
-	api.Get("/top-repos", getTopRepos)
+	api.Get("/top-repos", handlers.GetTopRepos)
+	api.Get("/trending", handlers.GetTrending)
+	api.Get("/repos/search", handlers.SearchRepos)
+	api.Get("/authors/:identity", analyzeRateLimit, handlers.GetAuthor)
+	api.Get("/orgs/:org", analyzeRateLimit, handlers.GetOrg)
+	api.Get("/compare", analyzeRateLimit, handlers.CompareRepos)
+	api.Get("/repos/:owner/:repo/codeowners", analyzeRateLimit, handlers.GetCodeOwners)
+	api.Get("/repos/:owner/:repo/wrapped", analyzeRateLimit, handlers.GetWrapped)
+	api.Get("/repos/:owner/:repo/commits", analyzeRateLimit, handlers.GetCommits)
+	api.Get("/repos/:owner/:repo/history", handlers.GetHistory)
+
+	// Admin routes, only enabled when ADMIN_TOKEN is set
+	admin := api.Group("/admin", middleware.AdminAuth(os.Getenv("ADMIN_TOKEN")))
+	admin.Get("/cache", handlers.AdminListCache)
+	admin.Delete("/repos/:owner/:repo/cache", handlers.AdminPurgeCache)
+	admin.Post("/repos/:owner/:repo/analyze", handlers.AdminReanalyze)
+	admin.Delete("/repos/:owner/:repo", handlers.AdminDeleteRepo)
+	admin.Put("/repos/:owner/:repo/hidden", handlers.AdminHideRepo)
+	admin.Delete("/repos/:owner/:repo/hidden", handlers.AdminUnhideRepo)
+	admin.Get("/exclusions", handlers.AdminListExclusions)
+	admin.Post("/exclusions", handlers.AdminAddExclusion)
+	admin.Delete("/exclusions", handlers.AdminRemoveExclusion)
 
 	// Root endpoint
 	app.Get("/", func(c *fiber.Ctx) error {
@@ -114,15 +147,47 @@ func main() {
 	log.Fatal(app.Listen(":" + port))
 }
 
-// getTopRepos handles the top repositories endpoint
-func getTopRepos(c *fiber.Ctx) error {
-	repos, err := database.GetTopRepos()
-	if err != nil {
-		log.Printf("Failed to get top repos: %v", err)
-		return middleware.InternalError(c, "Failed to fetch top repositories")
+// runMigrate handles `gitback migrate [up | down [steps] | status]`
+func runMigrate(args []string) {
+	if err := database.Init(os.Getenv("DATABASE_URL")); err != nil {
+		log.Fatalf("Database initialization failed: %v", err)
+	}
+	defer database.Close()
+
+	command := "up"
+	if len(args) > 0 {
+		command = args[0]
 	}
 
-	return c.JSON(fiber.Map{
-		"repos": repos,
-	})
+	switch command {
+	case "up":
+		if err := database.MigrateUp(); err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			var err error
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				log.Fatalf("down takes a positive number of migrations to revert")
+			}
+		}
+		if err := database.MigrateDown(steps); err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+	case "status":
+		states, err := database.MigrationStatus()
+		if err != nil {
+			log.Fatalf("Failed to read migration status: %v", err)
+		}
+		for _, state := range states {
+			applied := "pending"
+			if state.AppliedAt != nil {
+				applied = "applied " + state.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%03d_%s\t%s\n", state.Version, state.Name, applied)
+		}
+	default:
+		log.Fatalf("Unknown migrate command %q, expected up, down [steps] or status", command)
+	}
 }
@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// AdminAuth only lets through requests carrying token as a bearer token. With an empty token the admin
+// API is disabled
+func AdminAuth(token string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if token == "" {
+			return c.Status(fiber.StatusForbidden).JSON(ErrorResponse{
+				Error: "Admin API is disabled",
+				Code:  "ADMIN_DISABLED",
+			})
+		}
+
+		provided, ok := strings.CutPrefix(c.Get(fiber.HeaderAuthorization), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			return c.Status(fiber.StatusUnauthorized).JSON(ErrorResponse{
+				Error: "Invalid admin token",
+				Code:  "UNAUTHORIZED",
+			})
+		}
+
+		return c.Next()
+	}
+}
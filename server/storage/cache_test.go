@@ -0,0 +1,128 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// testCacheBackend checks the behavior every Cache implementation shares
+func testCacheBackend(t *testing.T, cache Cache) {
+	ctx := context.Background()
+
+	t.Run("missing key", func(t *testing.T) {
+		if _, _, err := cache.Get(ctx, "cache/missing.json"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Get of a missing key returned %v, want ErrNotFound", err)
+		}
+		if err := cache.Delete(ctx, "cache/missing.json"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Delete of a missing key returned %v, want ErrNotFound", err)
+		}
+	})
+
+	t.Run("put and get", func(t *testing.T) {
+		data := []byte(`{"totalCommits":3}`)
+		metadata := map[string]string{"username": "octo", "repo": "hello", "head_sha": "abc123"}
+
+		before := time.Now().Add(-time.Second)
+		if err := cache.Put(ctx, "cache/octo_hello.json", data, metadata); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+
+		got, info, err := cache.Get(ctx, "cache/octo_hello.json")
+		if err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Errorf("Get returned %q, want %q", got, data)
+		}
+		if info.Key != "cache/octo_hello.json" {
+			t.Errorf("info.Key = %q, want cache/octo_hello.json", info.Key)
+		}
+		if info.Size != int64(len(data)) {
+			t.Errorf("info.Size = %d, want %d", info.Size, len(data))
+		}
+		if info.Updated.Before(before) {
+			t.Errorf("info.Updated = %v, want after %v", info.Updated, before)
+		}
+		for k, v := range metadata {
+			if info.Metadata[k] != v {
+				t.Errorf("metadata %q = %q, want %q", k, info.Metadata[k], v)
+			}
+		}
+	})
+
+	t.Run("put replaces", func(t *testing.T) {
+		if err := cache.Put(ctx, "cache/replaced.json", []byte("old"), map[string]string{"head_sha": "old"}); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+		if err := cache.Put(ctx, "cache/replaced.json", []byte("new"), map[string]string{"head_sha": "new"}); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+
+		got, info, err := cache.Get(ctx, "cache/replaced.json")
+		if err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		if string(got) != "new" || info.Metadata["head_sha"] != "new" {
+			t.Errorf("Get returned %q with head_sha %q, want the second Put", got, info.Metadata["head_sha"])
+		}
+	})
+
+	t.Run("list", func(t *testing.T) {
+		keys := []string{"cache/wrapped/a_b_2024.json", "cache/wrapped/a_b_2025.json", "cache/wrapped/c_d_2025.json"}
+		for _, key := range keys {
+			if err := cache.Put(ctx, key, []byte(key), map[string]string{"schema_version": "2"}); err != nil {
+				t.Fatalf("Put %s failed: %v", key, err)
+			}
+		}
+
+		listed, err := cache.List(ctx, "cache/wrapped/a_b_")
+		if err != nil {
+			t.Fatalf("List failed: %v", err)
+		}
+		var listedKeys []string
+		for _, info := range listed {
+			listedKeys = append(listedKeys, info.Key)
+			if info.Size != int64(len(info.Key)) {
+				t.Errorf("listed size of %s = %d, want %d", info.Key, info.Size, len(info.Key))
+			}
+		}
+		if want := keys[:2]; !reflect.DeepEqual(listedKeys, want) {
+			t.Errorf("List returned %v, want %v", listedKeys, want)
+		}
+
+		empty, err := cache.List(ctx, "cache/nothing/")
+		if err != nil {
+			t.Fatalf("List failed: %v", err)
+		}
+		if len(empty) != 0 {
+			t.Errorf("List of an unused prefix returned %d objects", len(empty))
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		if err := cache.Put(ctx, "cache/deleted.json", []byte("x"), nil); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+		if err := cache.Delete(ctx, "cache/deleted.json"); err != nil {
+			t.Fatalf("Delete failed: %v", err)
+		}
+		if _, _, err := cache.Get(ctx, "cache/deleted.json"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Get after Delete returned %v, want ErrNotFound", err)
+		}
+		listed, err := cache.List(ctx, "cache/deleted.json")
+		if err != nil {
+			t.Fatalf("List failed: %v", err)
+		}
+		if len(listed) != 0 {
+			t.Errorf("List after Delete returned %v", listed)
+		}
+	})
+}
+
+func TestMemoryCache(t *testing.T) {
+	testCacheBackend(t, NewMemoryCache(1<<20))
+}
@@ -0,0 +1,216 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	// metadataSuffix marks the sidecar file holding an object's metadata
+	metadataSuffix = ".meta.json"
+	// tmpSuffix marks files still being written, they're renamed into place once complete
+	tmpSuffix = ".tmp"
+)
+
+// FilesystemCache stores cached objects as files below a root directory, with metadata in a sidecar file
+type FilesystemCache struct {
+	root string
+}
+
+// NewFilesystemCache creates a cache storing objects below dir, creating it if needed
+func NewFilesystemCache(dir string) (*FilesystemCache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	return &FilesystemCache{root: dir}, nil
+}
+
+func (f *FilesystemCache) Get(ctx context.Context, key string) ([]byte, *ObjectInfo, error) {
+	file, err := f.path(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil, ErrNotFound
+		}
+		return nil, nil, fmt.Errorf("failed to read object: %w", err)
+	}
+
+	info, err := f.stat(key, file)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return data, info, nil
+}
+
+func (f *FilesystemCache) ReadHeader(ctx context.Context, key string, n int64) ([]byte, error) {
+	file, err := f.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	reader, err := os.Open(file)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to read object: %w", err)
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(io.LimitReader(reader, n))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read object: %w", err)
+	}
+	return data, nil
+}
+
+func (f *FilesystemCache) Put(ctx context.Context, key string, data []byte, metadata map[string]string) error {
+	file, err := f.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	metadataJSON, err := json.Marshal(metadata)
+	if err != nil {
+		return fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+
+	// Metadata goes first so a reader never sees new data with stale metadata for long
+	if err := writeFileAtomic(file+metadataSuffix, metadataJSON); err != nil {
+		return err
+	}
+	return writeFileAtomic(file, data)
+}
+
+func (f *FilesystemCache) Delete(ctx context.Context, key string) error {
+	file, err := f.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(file); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("failed to delete object: %w", err)
+	}
+	os.Remove(file + metadataSuffix)
+	return nil
+}
+
+func (f *FilesystemCache) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	var objects []ObjectInfo
+
+	err := filepath.WalkDir(f.root, func(file string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || strings.HasSuffix(file, metadataSuffix) || strings.HasSuffix(file, tmpSuffix) {
+			return nil
+		}
+
+		rel, err := filepath.Rel(f.root, file)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+
+		info, err := f.stat(key, file)
+		if err != nil {
+			return err
+		}
+		objects = append(objects, *info)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list objects: %w", err)
+	}
+
+	sort.Slice(objects, func(i, j int) bool {
+		return objects[i].Key < objects[j].Key
+	})
+	return objects, nil
+}
+
+func (f *FilesystemCache) Close() error {
+	return nil
+}
+
+// path maps a key to a file below the root, refusing keys that would escape it
+func (f *FilesystemCache) path(key string) (string, error) {
+	if key == "" || path.Clean(key) != key || strings.HasPrefix(key, "/") || strings.HasPrefix(key, "..") ||
+		strings.HasSuffix(key, metadataSuffix) || strings.HasSuffix(key, tmpSuffix) {
+		return "", fmt.Errorf("invalid cache key %q", key)
+	}
+	return filepath.Join(f.root, filepath.FromSlash(key)), nil
+}
+
+func (f *FilesystemCache) stat(key, file string) (*ObjectInfo, error) {
+	stat, err := os.Stat(file)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to stat object: %w", err)
+	}
+
+	info := &ObjectInfo{
+		Key:     key,
+		Size:    stat.Size(),
+		Updated: stat.ModTime(),
+	}
+
+	if metadataJSON, err := os.ReadFile(file + metadataSuffix); err == nil {
+		if err := json.Unmarshal(metadataJSON, &info.Metadata); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal metadata: %w", err)
+		}
+	}
+
+	return info, nil
+}
+
+// writeFileAtomic writes through a temp file and a rename so readers never see a partial object. Each write
+// gets its own temp file, so concurrent writes of the same key can't interleave
+func writeFileAtomic(file string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(file), filepath.Base(file)+".*"+tmpSuffix)
+	if err != nil {
+		return fmt.Errorf("failed to write object: %w", err)
+	}
+
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0o644)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), file)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write object: %w", err)
+	}
+	return nil
+}
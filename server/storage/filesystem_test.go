@@ -0,0 +1,162 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestFilesystemCache(t *testing.T) {
+	cache, err := NewFilesystemCache(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFilesystemCache failed: %v", err)
+	}
+	testCacheBackend(t, cache)
+}
+
+func TestFilesystemCacheInvalidKeys(t *testing.T) {
+	cache, err := NewFilesystemCache(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFilesystemCache failed: %v", err)
+	}
+
+	for _, key := range []string{"", "/etc/passwd", "../escape.json", "cache/../../escape.json", "cache//double.json",
+		"cache/a.json" + metadataSuffix, "cache/a.json" + tmpSuffix} {
+		if err := cache.Put(context.Background(), key, []byte("x"), nil); err == nil {
+			t.Errorf("Put accepted invalid key %q", key)
+		}
+	}
+}
+
+// TestFilesystemCacheConcurrentPut races writers and readers on one key, every read has to return one
+// writer's complete object
+func TestFilesystemCacheConcurrentPut(t *testing.T) {
+	dir := t.TempDir()
+	cache, err := NewFilesystemCache(dir)
+	if err != nil {
+		t.Fatalf("NewFilesystemCache failed: %v", err)
+	}
+
+	const writers = 8
+	const key = "cache/octo_hello.json"
+	ctx := context.Background()
+
+	payloads := make([][]byte, writers)
+	for i := range payloads {
+		payloads[i] = bytes.Repeat([]byte{byte('a' + i)}, 1<<20)
+	}
+	if err := cache.Put(ctx, key, payloads[0], nil); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	complete := func(data []byte) bool {
+		return len(data) == len(payloads[0]) && bytes.Count(data, data[:1]) == len(data)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, writers*100)
+	for i := 0; i < writers; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				if err := cache.Put(ctx, key, payloads[i], map[string]string{"writer": fmt.Sprint(i)}); err != nil {
+					errs <- fmt.Errorf("Put failed: %w", err)
+				}
+			}
+		}(i)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				data, _, err := cache.Get(ctx, key)
+				if err != nil {
+					errs <- fmt.Errorf("Get failed: %w", err)
+					continue
+				}
+				if !complete(data) {
+					errs <- fmt.Errorf("Get returned a torn object of %d bytes", len(data))
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	data, _, err := cache.Get(ctx, key)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if !complete(data) {
+		t.Errorf("final object is torn")
+	}
+
+	leftovers, err := filepath.Glob(filepath.Join(dir, "cache", "*"+tmpSuffix))
+	if err != nil {
+		t.Fatalf("Glob failed: %v", err)
+	}
+	if len(leftovers) > 0 {
+		t.Errorf("temp files left behind: %v", leftovers)
+	}
+}
+
+func TestFilesystemCacheListSkipsTempFiles(t *testing.T) {
+	dir := t.TempDir()
+	cache, err := NewFilesystemCache(dir)
+	if err != nil {
+		t.Fatalf("NewFilesystemCache failed: %v", err)
+	}
+
+	if err := cache.Put(context.Background(), "cache/a.json", []byte("x"), nil); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	// A write interrupted before its rename
+	if err := os.WriteFile(filepath.Join(dir, "cache", "b.json.123"+tmpSuffix), []byte("partial"), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	listed, err := cache.List(context.Background(), "cache/")
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(listed) != 1 || listed[0].Key != "cache/a.json" {
+		var keys []string
+		for _, info := range listed {
+			keys = append(keys, info.Key)
+		}
+		t.Errorf("List returned %s, want only cache/a.json", strings.Join(keys, ", "))
+	}
+}
+
+func TestFilesystemCacheReadHeader(t *testing.T) {
+	cache, err := NewFilesystemCache(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFilesystemCache failed: %v", err)
+	}
+	ctx := context.Background()
+
+	for _, data := range []string{`{"totalCommits":3}`, `{}`} {
+		if err := cache.Put(ctx, "cache/a.json", []byte(data), nil); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+		header, err := cache.ReadHeader(ctx, "cache/a.json", objectHeaderSize)
+		if err != nil {
+			t.Fatalf("ReadHeader failed: %v", err)
+		}
+		if want := data[:min(len(data), objectHeaderSize)]; string(header) != want {
+			t.Errorf("ReadHeader returned %q, want %q", header, want)
+		}
+	}
+
+	if _, err := cache.ReadHeader(ctx, "cache/missing.json", objectHeaderSize); !errors.Is(err, ErrNotFound) {
+		t.Errorf("ReadHeader of a missing key returned %v, want ErrNotFound", err)
+	}
+}
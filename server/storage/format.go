@@ -0,0 +1,228 @@
+package storage
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// CACHE_SCHEMA_VERSION is the version of the cached response shape. Bump it whenever the shape changes,
+// and register a migration from the previous version if old entries can be brought up to date
+const CACHE_SCHEMA_VERSION = 2
+
+// LegacySchemaVersion is the version of plain JSON objects written before objects had a header
+const LegacySchemaVersion = 1
+
+// ErrSchemaVersion is returned for objects written with a schema version that can't be migrated
+var ErrSchemaVersion = errors.New("unsupported cache schema version")
+
+// Objects start with a 4 byte magic, a big endian uint16 schema version and a compression byte
+var objectMagic = []byte("GBC\x00")
+
+const objectHeaderSize = 7
+
+// Compression is the algorithm an object's payload is compressed with
+type Compression byte
+
+const (
+	CompressionNone Compression = 'n'
+	CompressionGzip Compression = 'g'
+	CompressionZstd Compression = 'z'
+)
+
+// ParseCompression reads a CACHE_COMPRESSION value
+func ParseCompression(name string) (Compression, error) {
+	switch name {
+	case "", "zstd":
+		return CompressionZstd, nil
+	case "gzip":
+		return CompressionGzip, nil
+	case "none":
+		return CompressionNone, nil
+	}
+	return 0, fmt.Errorf("unknown compression %q, expected zstd, gzip or none", name)
+}
+
+// Migration upgrades an object's JSON from one schema version to the next
+type Migration func(data []byte) ([]byte, error)
+
+var (
+	migrationsMu sync.RWMutex
+	migrations   = make(map[int]Migration)
+)
+
+// RegisterMigration registers how to upgrade objects written with schema version from to version from+1
+func RegisterMigration(from int, migration Migration) {
+	migrationsMu.Lock()
+	defer migrationsMu.Unlock()
+	migrations[from] = migration
+}
+
+var (
+	zstdEncoder, _ = zstd.NewWriter(nil)
+	zstdDecoder, _ = zstd.NewReader(nil)
+)
+
+// VersionedCache compresses objects and prefixes them with their schema version before handing them to
+// the backend, and decodes, migrates or rejects them on the way back
+type VersionedCache struct {
+	backend     Cache
+	compression Compression
+}
+
+// NewVersionedCache wraps backend so objects are stored compressed and versioned
+func NewVersionedCache(backend Cache, compression Compression) *VersionedCache {
+	return &VersionedCache{
+		backend:     backend,
+		compression: compression,
+	}
+}
+
+func (v *VersionedCache) Get(ctx context.Context, key string) ([]byte, *ObjectInfo, error) {
+	stored, info, err := v.backend.Get(ctx, key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	version, data, err := DecodeObject(stored)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to decode %s: %w", key, err)
+	}
+
+	if data, err = migrate(version, data); err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", key, err)
+	}
+
+	return data, info, nil
+}
+
+func (v *VersionedCache) Put(ctx context.Context, key string, data []byte, metadata map[string]string) error {
+	encoded, err := EncodeObject(data, v.compression)
+	if err != nil {
+		return err
+	}
+
+	versioned := make(map[string]string, len(metadata)+1)
+	for k, value := range metadata {
+		versioned[k] = value
+	}
+	versioned["schema_version"] = strconv.Itoa(CACHE_SCHEMA_VERSION)
+
+	return v.backend.Put(ctx, key, encoded, versioned)
+}
+
+func (v *VersionedCache) Delete(ctx context.Context, key string) error {
+	return v.backend.Delete(ctx, key)
+}
+
+func (v *VersionedCache) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	return v.backend.List(ctx, prefix)
+}
+
+func (v *VersionedCache) Close() error {
+	return v.backend.Close()
+}
+
+// EncodeObject compresses data and prefixes it with the current schema version
+func EncodeObject(data []byte, compression Compression) ([]byte, error) {
+	header := make([]byte, objectHeaderSize)
+	copy(header, objectMagic)
+	binary.BigEndian.PutUint16(header[4:6], CACHE_SCHEMA_VERSION)
+	header[6] = byte(compression)
+
+	switch compression {
+	case CompressionNone:
+		return append(header, data...), nil
+	case CompressionZstd:
+		return zstdEncoder.EncodeAll(data, header), nil
+	case CompressionGzip:
+		buf := bytes.NewBuffer(header)
+		writer := gzip.NewWriter(buf)
+		if _, err := writer.Write(data); err != nil {
+			return nil, fmt.Errorf("failed to compress object: %w", err)
+		}
+		if err := writer.Close(); err != nil {
+			return nil, fmt.Errorf("failed to compress object: %w", err)
+		}
+		return buf.Bytes(), nil
+	}
+	return nil, fmt.Errorf("unknown compression %q", compression)
+}
+
+// DecodeObject returns the schema version and decompressed JSON of a stored object. Objects without a
+// header are legacy plain JSON
+func DecodeObject(stored []byte) (int, []byte, error) {
+	version, err := ObjectSchemaVersion(stored)
+	if err != nil || version == LegacySchemaVersion {
+		return version, stored, err
+	}
+
+	payload := stored[objectHeaderSize:]
+
+	switch Compression(stored[6]) {
+	case CompressionNone:
+		return version, payload, nil
+	case CompressionZstd:
+		data, err := zstdDecoder.DecodeAll(payload, nil)
+		if err != nil {
+			return 0, nil, fmt.Errorf("failed to decompress object: %w", err)
+		}
+		return version, data, nil
+	case CompressionGzip:
+		reader, err := gzip.NewReader(bytes.NewReader(payload))
+		if err != nil {
+			return 0, nil, fmt.Errorf("failed to decompress object: %w", err)
+		}
+		defer reader.Close()
+
+		data, err := io.ReadAll(reader)
+		if err != nil {
+			return 0, nil, fmt.Errorf("failed to decompress object: %w", err)
+		}
+		return version, data, nil
+	}
+	return 0, nil, fmt.Errorf("unknown compression %q", stored[6])
+}
+
+// ObjectSchemaVersion reads the schema version from a stored object's header
+func ObjectSchemaVersion(stored []byte) (int, error) {
+	if !bytes.HasPrefix(stored, objectMagic) {
+		return LegacySchemaVersion, nil
+	}
+	if len(stored) < objectHeaderSize {
+		return 0, fmt.Errorf("truncated object header")
+	}
+	return int(binary.BigEndian.Uint16(stored[4:6])), nil
+}
+
+// migrate brings data from version up to CACHE_SCHEMA_VERSION one step at a time
+func migrate(version int, data []byte) ([]byte, error) {
+	if version > CACHE_SCHEMA_VERSION {
+		return nil, fmt.Errorf("%w: %d is newer than %d", ErrSchemaVersion, version, CACHE_SCHEMA_VERSION)
+	}
+
+	migrationsMu.RLock()
+	defer migrationsMu.RUnlock()
+
+	for ; version < CACHE_SCHEMA_VERSION; version++ {
+		migration, ok := migrations[version]
+		if !ok {
+			return nil, fmt.Errorf("%w: no migration from %d", ErrSchemaVersion, version)
+		}
+
+		var err error
+		if data, err = migration(data); err != nil {
+			return nil, fmt.Errorf("failed to migrate from schema version %d: %w", version, err)
+		}
+	}
+
+	return data, nil
+}
@@ -0,0 +1,165 @@
+package storage
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryCache is an in-process cache that evicts the least recently used objects once it holds
+// more than its byte budget
+type MemoryCache struct {
+	mu       sync.Mutex
+	maxBytes int64
+	size     int64
+	order    *list.List // front is most recently used
+	items    map[string]*list.Element
+}
+
+type memoryEntry struct {
+	info     ObjectInfo
+	data     []byte
+	storedAt time.Time
+}
+
+// NewMemoryCache creates an LRU cache holding at most maxBytes of object data
+func NewMemoryCache(maxBytes int64) *MemoryCache {
+	return &MemoryCache{
+		maxBytes: maxBytes,
+		order:    list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+func (m *MemoryCache) Get(ctx context.Context, key string) ([]byte, *ObjectInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	element, ok := m.items[key]
+	if !ok {
+		return nil, nil, ErrNotFound
+	}
+
+	m.order.MoveToFront(element)
+	entry := element.Value.(*memoryEntry)
+	info := entry.info
+	return entry.data, &info, nil
+}
+
+func (m *MemoryCache) Put(ctx context.Context, key string, data []byte, metadata map[string]string) error {
+	storedMetadata := make(map[string]string, len(metadata))
+	for k, v := range metadata {
+		storedMetadata[k] = v
+	}
+
+	return m.store(ObjectInfo{
+		Key:      key,
+		Size:     int64(len(data)),
+		Updated:  time.Now(),
+		Metadata: storedMetadata,
+	}, data)
+}
+
+// store adds an object with the given info, keeping Updated as is so a tier in front of another backend
+// reports the age of the original object
+func (m *MemoryCache) store(info ObjectInfo, data []byte) error {
+	size := int64(len(data))
+	if size > m.maxBytes {
+		return fmt.Errorf("object of %d bytes exceeds the memory cache size of %d bytes", size, m.maxBytes)
+	}
+
+	// Callers may reuse their buffers, the cache keeps its own copy
+	stored := make([]byte, len(data))
+	copy(stored, data)
+
+	info.Size = size
+	entry := &memoryEntry{info: info, data: stored, storedAt: time.Now()}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if element, ok := m.items[info.Key]; ok {
+		m.removeElement(element)
+	}
+
+	m.items[info.Key] = m.order.PushFront(entry)
+	m.size += size
+
+	for m.size > m.maxBytes {
+		m.removeElement(m.order.Back())
+	}
+
+	return nil
+}
+
+// getStoredSince returns the object at key if it was stored after since, dropping it if it's older
+func (m *MemoryCache) getStoredSince(key string, since time.Time) ([]byte, *ObjectInfo, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	element, ok := m.items[key]
+	if !ok {
+		return nil, nil, false
+	}
+
+	entry := element.Value.(*memoryEntry)
+	if !entry.storedAt.After(since) {
+		m.removeElement(element)
+		return nil, nil, false
+	}
+
+	m.order.MoveToFront(element)
+	info := entry.info
+	return entry.data, &info, true
+}
+
+// Usage returns how many objects and bytes the cache currently holds
+func (m *MemoryCache) Usage() (objects int, bytes int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.items), m.size
+}
+
+func (m *MemoryCache) Delete(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	element, ok := m.items[key]
+	if !ok {
+		return ErrNotFound
+	}
+	m.removeElement(element)
+	return nil
+}
+
+func (m *MemoryCache) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var objects []ObjectInfo
+	for key, element := range m.items {
+		if strings.HasPrefix(key, prefix) {
+			objects = append(objects, element.Value.(*memoryEntry).info)
+		}
+	}
+
+	sort.Slice(objects, func(i, j int) bool {
+		return objects[i].Key < objects[j].Key
+	})
+	return objects, nil
+}
+
+func (m *MemoryCache) Close() error {
+	return nil
+}
+
+// removeElement drops an entry, the caller must hold the lock
+func (m *MemoryCache) removeElement(element *list.Element) {
+	entry := m.order.Remove(element).(*memoryEntry)
+	delete(m.items, entry.info.Key)
+	m.size -= entry.info.Size
+}
@@ -0,0 +1,31 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// registerMigrations registers the upgrades between the schema versions VersionedCache reads
+func registerMigrations() {
+	RegisterMigration(LegacySchemaVersion, migrateLegacyObject)
+}
+
+// migrateLegacyObject brings a plain JSON entry up to the current shape. Wrapped and org reports are
+// unchanged. Analyses are only kept when they already have the sections added since, rebuilding them is
+// left to a fresh analysis since legacy commits carry no timezone and would show UTC times as local ones
+func migrateLegacyObject(data []byte) ([]byte, error) {
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(data, &object); err != nil {
+		return nil, err
+	}
+
+	if _, ok := object["commits"]; !ok {
+		return data, nil
+	}
+	for _, section := range []string{"activity", "contributors", "streaks"} {
+		if _, ok := object[section]; !ok {
+			return nil, fmt.Errorf("%w: legacy analysis without %s", ErrSchemaVersion, section)
+		}
+	}
+	return data, nil
+}
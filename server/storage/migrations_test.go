@@ -0,0 +1,40 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestMigrateLegacyObjects(t *testing.T) {
+	registerMigrations()
+	backend := NewMemoryCache(1 << 20)
+	cache := NewVersionedCache(backend, CompressionZstd)
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		data    string
+		wantErr error
+	}{
+		{"wrapped report", `{"year":2024,"totalCommits":3}`, nil},
+		{"current analysis", `{"commits":{"n":0},"activity":{},"contributors":[],"streaks":{}}`, nil},
+		{"analysis without activity", `{"commits":{"n":0},"contributors":[],"streaks":{}}`, ErrSchemaVersion},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Legacy objects are plain JSON without a header or metadata
+			if err := backend.Put(ctx, "cache/legacy.json", []byte(test.data), nil); err != nil {
+				t.Fatalf("Put failed: %v", err)
+			}
+
+			data, _, err := cache.Get(ctx, "cache/legacy.json")
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("Get returned %v, want %v", err, test.wantErr)
+			}
+			if err == nil && string(data) != test.data {
+				t.Errorf("Get returned %s, want %s", data, test.data)
+			}
+		})
+	}
+}
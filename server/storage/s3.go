@@ -0,0 +1,185 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// S3Config configures an S3 compatible cache, which covers AWS S3, MinIO and Cloudflare R2
+type S3Config struct {
+	Endpoint        string // host[:port] without scheme, s3.amazonaws.com for AWS
+	Region          string
+	Bucket          string
+	AccessKeyID     string // when empty, credentials are read from the AWS environment and IAM
+	SecretAccessKey string
+	UseSSL          bool
+	PathStyle       bool // MinIO and most self-hosted servers need path style bucket addressing
+}
+
+// S3Cache stores cached objects in an S3 compatible bucket
+type S3Cache struct {
+	client *minio.Client
+	bucket string
+}
+
+// NewS3Cache creates an S3 backed cache and checks the bucket is reachable
+func NewS3Cache(ctx context.Context, config S3Config) (*S3Cache, error) {
+	if config.Bucket == "" {
+		return nil, fmt.Errorf("S3_BUCKET environment variable not set")
+	}
+	if config.Endpoint == "" {
+		config.Endpoint = "s3.amazonaws.com"
+	}
+
+	creds := credentials.NewChainCredentials([]credentials.Provider{
+		&credentials.EnvAWS{},
+		&credentials.IAM{},
+	})
+	if config.AccessKeyID != "" {
+		creds = credentials.NewStaticV4(config.AccessKeyID, config.SecretAccessKey, "")
+	}
+
+	lookup := minio.BucketLookupAuto
+	if config.PathStyle {
+		lookup = minio.BucketLookupPath
+	}
+
+	client, err := minio.New(config.Endpoint, &minio.Options{
+		Creds:        creds,
+		Secure:       config.UseSSL,
+		Region:       config.Region,
+		BucketLookup: lookup,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create s3 client: %w", err)
+	}
+
+	exists, err := client.BucketExists(ctx, config.Bucket)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check bucket: %w", err)
+	}
+	if !exists {
+		return nil, fmt.Errorf("bucket %s does not exist", config.Bucket)
+	}
+
+	log.Printf("S3 Storage initialized with bucket: %s at %s", config.Bucket, config.Endpoint)
+	return &S3Cache{
+		client: client,
+		bucket: config.Bucket,
+	}, nil
+}
+
+func (s *S3Cache) Get(ctx context.Context, key string) ([]byte, *ObjectInfo, error) {
+	obj, err := s.client.GetObject(ctx, s.bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, nil, s3Error(err)
+	}
+	defer obj.Close()
+
+	// The request is only sent once the object is used, so a missing key shows up here
+	stat, err := obj.Stat()
+	if err != nil {
+		return nil, nil, s3Error(err)
+	}
+
+	data, err := io.ReadAll(obj)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read object: %w", err)
+	}
+
+	return data, s3ObjectInfo(stat), nil
+}
+
+func (s *S3Cache) ReadHeader(ctx context.Context, key string, n int64) ([]byte, error) {
+	var opts minio.GetObjectOptions
+	if err := opts.SetRange(0, n-1); err != nil {
+		return nil, err
+	}
+
+	obj, err := s.client.GetObject(ctx, s.bucket, key, opts)
+	if err != nil {
+		return nil, s3Error(err)
+	}
+	defer obj.Close()
+
+	data, err := io.ReadAll(obj)
+	if err != nil {
+		return nil, s3Error(err)
+	}
+	return data, nil
+}
+
+func (s *S3Cache) Put(ctx context.Context, key string, data []byte, metadata map[string]string) error {
+	_, err := s.client.PutObject(ctx, s.bucket, key, bytes.NewReader(data), int64(len(data)), minio.PutObjectOptions{
+		ContentType:  "application/octet-stream",
+		UserMetadata: metadata,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write object: %w", err)
+	}
+	return nil
+}
+
+func (s *S3Cache) Delete(ctx context.Context, key string) error {
+	// S3 deletes succeed for missing keys, check first so callers can tell
+	if _, err := s.client.StatObject(ctx, s.bucket, key, minio.StatObjectOptions{}); err != nil {
+		return s3Error(err)
+	}
+
+	if err := s.client.RemoveObject(ctx, s.bucket, key, minio.RemoveObjectOptions{}); err != nil {
+		return fmt.Errorf("failed to delete object: %w", err)
+	}
+	return nil
+}
+
+func (s *S3Cache) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	var objects []ObjectInfo
+
+	// Metadata in listings is a MinIO extension, other servers leave it empty
+	for object := range s.client.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{
+		Prefix:       prefix,
+		Recursive:    true,
+		WithMetadata: true,
+	}) {
+		if object.Err != nil {
+			return nil, fmt.Errorf("failed to list objects: %w", object.Err)
+		}
+		objects = append(objects, *s3ObjectInfo(object))
+	}
+
+	return objects, nil
+}
+
+func (s *S3Cache) Close() error {
+	return nil
+}
+
+func s3Error(err error) error {
+	switch minio.ToErrorResponse(err).Code {
+	case "NoSuchKey", "NotFound":
+		return ErrNotFound
+	}
+	return err
+}
+
+func s3ObjectInfo(object minio.ObjectInfo) *ObjectInfo {
+	// S3 returns metadata keys in header casing, GCS and the other backends keep them as written
+	metadata := make(map[string]string, len(object.UserMetadata))
+	for k, v := range object.UserMetadata {
+		metadata[strings.ToLower(strings.TrimPrefix(k, "X-Amz-Meta-"))] = v
+	}
+
+	return &ObjectInfo{
+		Key:      object.Key,
+		Size:     object.Size,
+		Updated:  object.LastModified,
+		Metadata: metadata,
+	}
+}
@@ -0,0 +1,301 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeS3 is an in-process S3 server covering the calls S3Cache makes, path style only
+type fakeS3 struct {
+	bucket string
+
+	mu      sync.Mutex
+	objects map[string]fakeS3Object
+	ranges  []string // Range headers of object GETs, empty for full reads
+}
+
+type fakeS3Object struct {
+	data     []byte
+	metadata http.Header // X-Amz-Meta-* headers as sent
+	modified time.Time
+}
+
+func newFakeS3(t *testing.T, bucket string) (*fakeS3, *httptest.Server) {
+	fake := &fakeS3{
+		bucket:  bucket,
+		objects: make(map[string]fakeS3Object),
+	}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	return fake, server
+}
+
+// newTestS3Cache starts a fake S3 server and connects an S3Cache to it
+func newTestS3Cache(t *testing.T) (*S3Cache, *fakeS3) {
+	fake, server := newFakeS3(t, "gitback-test")
+
+	cache, err := NewS3Cache(context.Background(), S3Config{
+		Endpoint:        strings.TrimPrefix(server.URL, "http://"),
+		Region:          "us-east-1",
+		Bucket:          "gitback-test",
+		AccessKeyID:     "test",
+		SecretAccessKey: "test-secret",
+		PathStyle:       true,
+	})
+	if err != nil {
+		t.Fatalf("NewS3Cache failed: %v", err)
+	}
+	return cache, fake
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if bucket != f.bucket {
+		f.error(w, http.StatusNotFound, "NoSuchBucket", bucket, key)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch {
+	case key == "" && r.Method == http.MethodHead:
+		w.WriteHeader(http.StatusOK)
+	case key == "" && r.Method == http.MethodGet && r.URL.Query().Get("list-type") == "2":
+		f.list(w, r.URL.Query())
+	case key != "" && r.Method == http.MethodPut:
+		f.put(w, r, key)
+	case key != "" && (r.Method == http.MethodGet || r.Method == http.MethodHead):
+		object, ok := f.objects[key]
+		if !ok {
+			f.error(w, http.StatusNotFound, "NoSuchKey", bucket, key)
+			return
+		}
+		for name, values := range object.metadata {
+			w.Header()[name] = values
+		}
+		data, status := object.data, http.StatusOK
+		if r.Method == http.MethodGet {
+			f.ranges = append(f.ranges, r.Header.Get("Range"))
+			var start, end int
+			if _, err := fmt.Sscanf(r.Header.Get("Range"), "bytes=%d-%d", &start, &end); err == nil {
+				end = min(end, len(data)-1)
+				w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, len(data)))
+				data, status = data[start:end+1], http.StatusPartialContent
+			}
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		w.Header().Set("Last-Modified", object.modified.UTC().Format(http.TimeFormat))
+		w.Header().Set("ETag", `"`+fmt.Sprintf("%x", len(object.data))+`"`)
+		w.WriteHeader(status)
+		if r.Method == http.MethodGet {
+			w.Write(data)
+		}
+	case key != "" && r.Method == http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		f.error(w, http.StatusNotImplemented, "NotImplemented", bucket, key)
+	}
+}
+
+func (f *fakeS3) put(w http.ResponseWriter, r *http.Request, key string) {
+	var body io.Reader = r.Body
+	if strings.HasPrefix(r.Header.Get("X-Amz-Content-Sha256"), "STREAMING-") {
+		body = decodeAWSChunked(r.Body)
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		f.error(w, http.StatusBadRequest, "IncompleteBody", f.bucket, key)
+		return
+	}
+
+	metadata := make(http.Header)
+	for name, values := range r.Header {
+		if strings.HasPrefix(name, "X-Amz-Meta-") {
+			metadata[name] = values
+		}
+	}
+
+	f.objects[key] = fakeS3Object{data: data, metadata: metadata, modified: time.Now()}
+	w.Header().Set("ETag", `"`+fmt.Sprintf("%x", len(data))+`"`)
+	w.WriteHeader(http.StatusOK)
+}
+
+func (f *fakeS3) list(w http.ResponseWriter, query url.Values) {
+	type content struct {
+		Key          string
+		LastModified string
+		ETag         string
+		Size         int
+		StorageClass string
+		UserMetadata struct {
+			Items []struct {
+				XMLName xml.Name
+				Value   string `xml:",chardata"`
+			}
+		} `xml:"UserMetadata,omitempty"`
+	}
+	result := struct {
+		XMLName     xml.Name `xml:"ListBucketResult"`
+		Name        string
+		Prefix      string
+		KeyCount    int
+		MaxKeys     int
+		IsTruncated bool
+		Contents    []content
+	}{Name: f.bucket, Prefix: query.Get("prefix"), MaxKeys: 1000}
+
+	var keys []string
+	for key := range f.objects {
+		if strings.HasPrefix(key, query.Get("prefix")) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		object := f.objects[key]
+		item := content{
+			Key:          key,
+			LastModified: object.modified.UTC().Format(time.RFC3339),
+			ETag:         `"` + fmt.Sprintf("%x", len(object.data)) + `"`,
+			Size:         len(object.data),
+			StorageClass: "STANDARD",
+		}
+		// User metadata in listings is a MinIO extension, only sent when asked for
+		if query.Get("metadata") == "true" {
+			for name, values := range object.metadata {
+				item.UserMetadata.Items = append(item.UserMetadata.Items, struct {
+					XMLName xml.Name
+					Value   string `xml:",chardata"`
+				}{XMLName: xml.Name{Local: name}, Value: values[0]})
+			}
+		}
+		result.Contents = append(result.Contents, item)
+	}
+	result.KeyCount = len(result.Contents)
+
+	w.Header().Set("Content-Type", "application/xml")
+	w.Write([]byte(xml.Header))
+	xml.NewEncoder(w).Encode(result)
+}
+
+func (f *fakeS3) error(w http.ResponseWriter, status int, code, bucket, key string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, `%s<Error><Code>%s</Code><Message>%s</Message><BucketName>%s</BucketName><Key>%s</Key><RequestId>test</RequestId></Error>`,
+		xml.Header, code, code, bucket, key)
+}
+
+// decodeAWSChunked strips the chunk framing of a streaming signed upload: "<hex size>;chunk-signature=...",
+// CRLF, the data, CRLF, ending with a zero size chunk
+func decodeAWSChunked(body io.Reader) io.Reader {
+	reader := bufio.NewReader(body)
+	var decoded bytes.Buffer
+	for {
+		header, err := reader.ReadString('\n')
+		if err != nil {
+			break
+		}
+		sizeHex, _, _ := strings.Cut(strings.TrimSpace(header), ";")
+		size, err := strconv.ParseInt(sizeHex, 16, 64)
+		if err != nil || size == 0 {
+			break
+		}
+		if _, err := io.CopyN(&decoded, reader, size); err != nil {
+			break
+		}
+		reader.ReadString('\n')
+	}
+	return &decoded
+}
+
+func TestS3Cache(t *testing.T) {
+	cache, _ := newTestS3Cache(t)
+	testCacheBackend(t, cache)
+}
+
+func TestS3CacheMetadataCasing(t *testing.T) {
+	cache, _ := newTestS3Cache(t)
+	ctx := context.Background()
+
+	// S3 hands metadata back in header casing, the backend has to return the keys as written
+	metadata := map[string]string{"head_sha": "abc123", "cached_at": "2024-01-01T00:00:00Z", "schema_version": "2"}
+	if err := cache.Put(ctx, "cache/octo_hello.json", []byte("x"), metadata); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	_, info, err := cache.Get(ctx, "cache/octo_hello.json")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	for k, v := range metadata {
+		if info.Metadata[k] != v {
+			t.Errorf("Get metadata %q = %q, want %q (got %v)", k, info.Metadata[k], v, info.Metadata)
+		}
+	}
+
+	listed, err := cache.List(ctx, "cache/")
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(listed) != 1 || listed[0].Metadata["schema_version"] != "2" {
+		t.Errorf("List returned %+v, want cache/octo_hello.json with schema_version 2", listed)
+	}
+}
+
+func TestS3CacheMissingBucket(t *testing.T) {
+	_, server := newFakeS3(t, "other-bucket")
+
+	_, err := NewS3Cache(context.Background(), S3Config{
+		Endpoint:        strings.TrimPrefix(server.URL, "http://"),
+		Region:          "us-east-1",
+		Bucket:          "gitback-test",
+		AccessKeyID:     "test",
+		SecretAccessKey: "test-secret",
+		PathStyle:       true,
+	})
+	if err == nil {
+		t.Fatal("NewS3Cache succeeded for a missing bucket")
+	}
+}
+
+func TestS3CacheReadHeader(t *testing.T) {
+	cache, fake := newTestS3Cache(t)
+	ctx := context.Background()
+
+	if err := cache.Put(ctx, "cache/octo_hello.json", []byte(`{"totalCommits":3}`), nil); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	header, err := cache.ReadHeader(ctx, "cache/octo_hello.json", objectHeaderSize)
+	if err != nil {
+		t.Fatalf("ReadHeader failed: %v", err)
+	}
+	if string(header) != `{"total` {
+		t.Errorf("ReadHeader returned %q, want the first %d bytes", header, objectHeaderSize)
+	}
+	if len(fake.ranges) != 1 || fake.ranges[0] != "bytes=0-6" {
+		t.Errorf("object reads sent ranges %q, want one read of bytes=0-6", fake.ranges)
+	}
+
+	if _, err := cache.ReadHeader(ctx, "cache/missing.json", objectHeaderSize); !errors.Is(err, ErrNotFound) {
+		t.Errorf("ReadHeader of a missing key returned %v, want ErrNotFound", err)
+	}
+}
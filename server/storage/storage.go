@@ -0,0 +1,386 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	database "github.com/immatheus/gitback/databases"
+)
+
+// ErrNotFound is returned by a Cache when no object exists for a key
+var ErrNotFound = errors.New("cache object not found")
+
+// ObjectInfo describes a cached object
+type ObjectInfo struct {
+	Key      string            `json:"key"`
+	Size     int64             `json:"size"`
+	Updated  time.Time         `json:"updated"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// Cache is an object store for cached analyses
+type Cache interface {
+	// Get returns the data and info of the object at key, or ErrNotFound
+	Get(ctx context.Context, key string) ([]byte, *ObjectInfo, error)
+	// Put creates or replaces the object at key
+	Put(ctx context.Context, key string, data []byte, metadata map[string]string) error
+	// Delete removes the object at key, or returns ErrNotFound
+	Delete(ctx context.Context, key string) error
+	// List returns every object whose key starts with prefix
+	List(ctx context.Context, prefix string) ([]ObjectInfo, error)
+	Close() error
+}
+
+// HeaderReader is implemented by backends that can read the start of an object without fetching all of it
+type HeaderReader interface {
+	// ReadHeader returns up to the first n bytes of the object at key, or ErrNotFound
+	ReadHeader(ctx context.Context, key string, n int64) ([]byte, error)
+}
+
+var cache Cache
+
+// objects is the backend below the versioned format, nil for the memory backend which keeps plain JSON
+var objects Cache
+
+// Init sets up the cache backend chosen by CACHE_BACKEND: gcs, s3, filesystem or memory. Without it, GCS is
+// used when GCP_BUCKET_NAME is set. Objects in remote backends are compressed with CACHE_COMPRESSION
+func Init() error {
+	backend := strings.ToLower(os.Getenv("CACHE_BACKEND"))
+	if backend == "" && os.Getenv("GCP_BUCKET_NAME") != "" {
+		backend = "gcs"
+	}
+
+	var err error
+	switch backend {
+	case "gcs":
+		cache, err = NewGCSCache(context.Background(), os.Getenv("GCP_BUCKET_NAME"))
+	case "s3":
+		cache, err = NewS3Cache(context.Background(), S3Config{
+			Endpoint:        os.Getenv("S3_ENDPOINT"),
+			Region:          os.Getenv("S3_REGION"),
+			Bucket:          os.Getenv("S3_BUCKET"),
+			AccessKeyID:     os.Getenv("S3_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("S3_SECRET_ACCESS_KEY"),
+			UseSSL:          os.Getenv("S3_USE_SSL") != "false",
+			PathStyle:       os.Getenv("S3_PATH_STYLE") == "true",
+		})
+	case "filesystem":
+		dir := os.Getenv("CACHE_DIR")
+		if dir == "" {
+			return fmt.Errorf("CACHE_DIR environment variable not set")
+		}
+		cache, err = NewFilesystemCache(dir)
+	case "memory":
+		maxMB := 256
+		if value := os.Getenv("CACHE_MEMORY_MB"); value != "" {
+			if maxMB, err = strconv.Atoi(value); err != nil || maxMB <= 0 {
+				return fmt.Errorf("CACHE_MEMORY_MB must be a positive number of megabytes")
+			}
+		}
+		cache = NewMemoryCache(int64(maxMB) * 1024 * 1024)
+	case "":
+		return fmt.Errorf("no cache configured, set CACHE_BACKEND or GCP_BUCKET_NAME")
+	default:
+		return fmt.Errorf("unknown CACHE_BACKEND %q, expected gcs, s3, filesystem or memory", backend)
+	}
+
+	if err != nil {
+		cache = nil
+		return err
+	}
+
+	if backend != "memory" {
+		registerMigrations()
+		compression, err := ParseCompression(strings.ToLower(os.Getenv("CACHE_COMPRESSION")))
+		if err != nil {
+			cache = nil
+			return err
+		}
+		objects = cache
+		cache = NewVersionedCache(objects, compression)
+	}
+
+	// Remote backends get an in-process tier for hot repos, CACHE_MEMORY_TIER_MB=0 turns it off. It sits
+	// above the versioned format so hits are served without decompressing
+	if backend != "memory" {
+		tierMB := 128
+		if value := os.Getenv("CACHE_MEMORY_TIER_MB"); value != "" {
+			if tierMB, err = strconv.Atoi(value); err != nil || tierMB < 0 {
+				return fmt.Errorf("CACHE_MEMORY_TIER_MB must be a number of megabytes")
+			}
+		}
+		tierTTL := DefaultMemoryTierTTL
+		if value := os.Getenv("CACHE_MEMORY_TIER_TTL"); value != "" {
+			if tierTTL, err = time.ParseDuration(value); err != nil || tierTTL <= 0 {
+				return fmt.Errorf("CACHE_MEMORY_TIER_TTL must be a positive duration such as 30s")
+			}
+		}
+		if tierMB > 0 {
+			cache = NewTieredCache(int64(tierMB)*1024*1024, tierTTL, cache)
+			log.Printf("Storage cache memory tier enabled with %d MB for %v", tierMB, tierTTL)
+		}
+	}
+
+	log.Printf("Storage cache initialized with %s backend", backend)
+	return nil
+}
+
+// Stats returns the memory tier's hit and miss counts, zero when there is no memory tier
+func Stats() CacheStats {
+	if tiered, ok := cache.(*TieredCache); ok {
+		return tiered.Stats()
+	}
+	return CacheStats{}
+}
+
+// Close closes the cache backend
+func Close() error {
+	if cache != nil {
+		return cache.Close()
+	}
+	return nil
+}
+
+// CacheKey generates a cache key for a repository
+func CacheKey(username, repo string) string {
+	return fmt.Sprintf("cache/%s_%s.json", strings.ToLower(username), strings.ToLower(repo))
+}
+
+// WrappedCacheKey generates a cache key for a repository's year in review
+func WrappedCacheKey(username, repo string, year int) string {
+	return fmt.Sprintf("%s%d.json", wrappedPrefix(username, repo), year)
+}
+
+// OrgCacheKey generates a cache key for the combined report of a GitHub org or user
+func OrgCacheKey(org string) string {
+	return fmt.Sprintf("cache/orgs/%s.json", strings.ToLower(org))
+}
+
+func wrappedPrefix(username, repo string) string {
+	return fmt.Sprintf("cache/wrapped/%s_%s_", strings.ToLower(username), strings.ToLower(repo))
+}
+
+const CACHE_EXPIRATION = 48 * time.Hour
+
+// DefaultMemoryTierTTL is how long the memory tier serves an object before checking the backend again, so
+// purges and refreshes made by other instances show up
+const DefaultMemoryTierTTL = 30 * time.Second
+
+// CACHE_MAX_STALENESS is the oldest an expired entry can be and still be served while it's refreshed,
+// past it requests wait for a new analysis
+const CACHE_MAX_STALENESS = 7 * 24 * time.Hour
+
+// CachedObject is a cache hit along with how fresh it is
+type CachedObject struct {
+	Data     []byte
+	CachedAt time.Time
+	HeadSHA  string // commit the analysis was made at, empty for entries written before it was recorded
+	// Stale is set once the object is older than CACHE_EXPIRATION, it can still be served while it's refreshed
+	Stale bool
+	// Expired is set once the object is older than CACHE_MAX_STALENESS
+	Expired bool
+}
+
+// GetCachedAnalysis returns the cached analysis JSON as stored, nil on a miss, so it can be served without
+// decoding and encoding it again. Stale and expired analyses are returned too, the caller decides whether
+// they can still be served, since an unchanged HEAD keeps an analysis valid regardless of its age
+func GetCachedAnalysis(username, repo string) (*CachedObject, error) {
+	return GetCachedObject(CacheKey(username, repo))
+}
+
+// GetCachedObject returns the object at key flagged by age, nil on a miss
+func GetCachedObject(key string) (*CachedObject, error) {
+	if cache == nil {
+		return nil, fmt.Errorf("storage cache not initialized")
+	}
+
+	start := time.Now()
+
+	data, info, err := cache.Get(context.Background(), key)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			log.Printf("[CACHE] Cache miss for %s (took %v)", key, time.Since(start))
+			return nil, nil
+		}
+		if errors.Is(err, ErrSchemaVersion) {
+			// The next analysis overwrites it in the current format
+			log.Printf("[CACHE] Ignoring %v", err)
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read cache: %w", err)
+	}
+
+	age := time.Since(info.Updated)
+	log.Printf("[CACHE] Cache hit for %s! (took %v, cached %v ago)", key, time.Since(start), age)
+
+	return &CachedObject{
+		Data:     data,
+		CachedAt: info.Updated,
+		HeadSHA:  info.Metadata["head_sha"],
+		Stale:    age > CACHE_EXPIRATION,
+		Expired:  age > CACHE_MAX_STALENESS,
+	}, nil
+}
+
+// StoreInCache stores analysis data in the cache along with the HEAD commit it was made at
+func StoreInCache(username, repo, headSHA string, data map[string]interface{}) error {
+	metadata := map[string]string{
+		"username": username,
+		"repo":     repo,
+		"head_sha": headSHA,
+	}
+	if err := StoreCachedJSON(CacheKey(username, repo), data, metadata); err != nil {
+		return err
+	}
+
+	// Update last cached timestamp in database
+	go func() {
+		if err := database.UpdateLastCachedAt(username, repo); err != nil {
+			log.Printf("[CACHE] Failed to update last cached timestamp for %s/%s: %v", username, repo, err)
+		}
+	}()
+
+	return nil
+}
+
+// StoreCachedJSON writes v as JSON to key, adding a cached_at timestamp to the metadata
+func StoreCachedJSON(key string, v interface{}, metadata map[string]string) error {
+	if cache == nil {
+		return fmt.Errorf("storage cache not initialized")
+	}
+
+	start := time.Now()
+
+	// Marshal data to JSON
+	jsonData, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to marshal data: %w", err)
+	}
+
+	objectMetadata := map[string]string{
+		"cached_at": time.Now().Format(time.RFC3339),
+	}
+	for k, value := range metadata {
+		objectMetadata[k] = value
+	}
+
+	if err := cache.Put(context.Background(), key, jsonData, objectMetadata); err != nil {
+		return fmt.Errorf("failed to write cache: %w", err)
+	}
+
+	log.Printf("[CACHE] Successfully cached %s (took %v, size: %.2f KB)",
+		key, time.Since(start), float64(len(jsonData))/1024)
+
+	return nil
+}
+
+// ClearCache removes the cached analysis and wrapped reports of a repository, returning how many
+// objects were deleted
+func ClearCache(username, repo string) (int, error) {
+	if cache == nil {
+		return 0, fmt.Errorf("storage cache not initialized")
+	}
+
+	keys := []string{CacheKey(username, repo)}
+
+	wrapped, err := cache.List(context.Background(), wrappedPrefix(username, repo))
+	if err != nil {
+		return 0, fmt.Errorf("failed to list wrapped cache: %w", err)
+	}
+	for _, info := range wrapped {
+		// The prefix of owner/repo also matches owner/repo_other, only keep keys ending in a year
+		year := strings.TrimSuffix(strings.TrimPrefix(info.Key, wrappedPrefix(username, repo)), ".json")
+		if _, err := strconv.Atoi(year); err == nil {
+			keys = append(keys, info.Key)
+		}
+	}
+
+	deleted := 0
+	for _, key := range keys {
+		if err := cache.Delete(context.Background(), key); err != nil {
+			if errors.Is(err, ErrNotFound) {
+				continue
+			}
+			return deleted, fmt.Errorf("failed to delete cache: %w", err)
+		}
+		deleted++
+	}
+
+	log.Printf("[CACHE] Cleared %d cached objects for %s/%s", deleted, username, repo)
+	return deleted, nil
+}
+
+// ListCache returns the info of every cached object whose key starts with prefix
+func ListCache(prefix string) ([]ObjectInfo, error) {
+	if cache == nil {
+		return nil, fmt.Errorf("storage cache not initialized")
+	}
+	return cache.List(context.Background(), prefix)
+}
+
+// CacheEntry is a cached object along with the schema version it was written with
+type CacheEntry struct {
+	ObjectInfo
+	SchemaVersion int `json:"schemaVersion"`
+}
+
+// ListCacheEntries returns every cached object whose key starts with prefix with its schema version. The
+// version is read from the object's metadata, or from its header for backends that don't list metadata
+func ListCacheEntries(prefix string) ([]CacheEntry, error) {
+	infos, err := ListCache(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]CacheEntry, 0, len(infos))
+	for _, info := range infos {
+		entry := CacheEntry{ObjectInfo: info, SchemaVersion: CACHE_SCHEMA_VERSION}
+
+		if objects != nil {
+			if entry.SchemaVersion, err = objectSchemaVersion(info); err != nil {
+				return nil, err
+			}
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+func objectSchemaVersion(info ObjectInfo) (int, error) {
+	if value, ok := info.Metadata["schema_version"]; ok {
+		if version, err := strconv.Atoi(value); err == nil {
+			return version, nil
+		}
+	}
+
+	var header []byte
+	var err error
+	if reader, ok := objects.(HeaderReader); ok {
+		header, err = reader.ReadHeader(context.Background(), info.Key, objectHeaderSize)
+	} else {
+		header, _, err = objects.Get(context.Background(), info.Key)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read %s: %w", info.Key, err)
+	}
+	return ObjectSchemaVersion(header)
+}
+
+// DeleteCacheObject removes the object at key
+func DeleteCacheObject(key string) error {
+	if cache == nil {
+		return fmt.Errorf("storage cache not initialized")
+	}
+	if err := cache.Delete(context.Background(), key); err != nil && !errors.Is(err, ErrNotFound) {
+		return fmt.Errorf("failed to delete cache: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"context"
+	"testing"
+)
+
+// TestListCacheEntries reads schema versions from metadata, and from the object header for objects
+// listed without it
+func TestListCacheEntries(t *testing.T) {
+	backend, err := NewFilesystemCache(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFilesystemCache failed: %v", err)
+	}
+	previousCache, previousObjects := cache, objects
+	objects, cache = backend, NewVersionedCache(backend, CompressionZstd)
+	t.Cleanup(func() { cache, objects = previousCache, previousObjects })
+
+	ctx := context.Background()
+	if err := cache.Put(ctx, "cache/current.json", []byte(`{}`), nil); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	encoded, err := EncodeObject([]byte(`{}`), CompressionZstd)
+	if err != nil {
+		t.Fatalf("EncodeObject failed: %v", err)
+	}
+	if err := backend.Put(ctx, "cache/unlabelled.json", encoded, nil); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if err := backend.Put(ctx, "cache/legacy.json", []byte(`{"commits":[]}`), nil); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	entries, err := ListCacheEntries("cache/")
+	if err != nil {
+		t.Fatalf("ListCacheEntries failed: %v", err)
+	}
+	want := map[string]int{
+		"cache/current.json":    CACHE_SCHEMA_VERSION,
+		"cache/unlabelled.json": CACHE_SCHEMA_VERSION,
+		"cache/legacy.json":     LegacySchemaVersion,
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("ListCacheEntries returned %d entries, want %d", len(entries), len(want))
+	}
+	for _, entry := range entries {
+		if entry.SchemaVersion != want[entry.Key] {
+			t.Errorf("%s schema version = %d, want %d", entry.Key, entry.SchemaVersion, want[entry.Key])
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// CacheStats counts where cache reads were served from
+type CacheStats struct {
+	MemoryHits    int64 `json:"memoryHits"`
+	BackendHits   int64 `json:"backendHits"`
+	Misses        int64 `json:"misses"`
+	MemoryObjects int   `json:"memoryObjects"`
+	MemoryBytes   int64 `json:"memoryBytes"`
+}
+
+// TieredCache keeps the raw bytes of hot objects in an in-process LRU in front of a slower backend.
+// Writes go to both tiers, reads fall through to the backend and fill the memory tier on the way back.
+// Other instances can replace or delete objects in a shared backend, so the memory tier only serves an
+// object for ttl after loading it
+type TieredCache struct {
+	memory  *MemoryCache
+	backend Cache
+	ttl     time.Duration
+
+	memoryHits  atomic.Int64
+	backendHits atomic.Int64
+	misses      atomic.Int64
+}
+
+// NewTieredCache puts an LRU of memoryBytes in front of backend, holding objects for at most ttl
+func NewTieredCache(memoryBytes int64, ttl time.Duration, backend Cache) *TieredCache {
+	return &TieredCache{
+		memory:  NewMemoryCache(memoryBytes),
+		backend: backend,
+		ttl:     ttl,
+	}
+}
+
+func (t *TieredCache) Get(ctx context.Context, key string) ([]byte, *ObjectInfo, error) {
+	if data, info, ok := t.memory.getStoredSince(key, time.Now().Add(-t.ttl)); ok {
+		t.memoryHits.Add(1)
+		return data, info, nil
+	}
+
+	data, info, err := t.backend.Get(ctx, key)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			t.misses.Add(1)
+		}
+		return nil, nil, err
+	}
+
+	t.backendHits.Add(1)
+	// Objects bigger than the whole memory tier are only served from the backend
+	t.memory.store(*info, data)
+
+	return data, info, nil
+}
+
+func (t *TieredCache) Put(ctx context.Context, key string, data []byte, metadata map[string]string) error {
+	if err := t.backend.Put(ctx, key, data, metadata); err != nil {
+		// Don't leave an older copy in memory that no longer matches the backend
+		t.memory.Delete(ctx, key)
+		return err
+	}
+
+	t.memory.Put(ctx, key, data, metadata)
+	return nil
+}
+
+func (t *TieredCache) Delete(ctx context.Context, key string) error {
+	t.memory.Delete(ctx, key)
+	return t.backend.Delete(ctx, key)
+}
+
+func (t *TieredCache) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	return t.backend.List(ctx, prefix)
+}
+
+func (t *TieredCache) Close() error {
+	return t.backend.Close()
+}
+
+// Stats returns the hit and miss counts since startup and the memory tier's usage
+func (t *TieredCache) Stats() CacheStats {
+	objects, bytes := t.memory.Usage()
+	return CacheStats{
+		MemoryHits:    t.memoryHits.Load(),
+		BackendHits:   t.backendHits.Load(),
+		Misses:        t.misses.Load(),
+		MemoryObjects: objects,
+		MemoryBytes:   bytes,
+	}
+}
@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTieredCache(t *testing.T) {
+	testCacheBackend(t, NewTieredCache(1<<20, time.Minute, NewMemoryCache(1<<20)))
+}
+
+func TestTieredCacheServesFromMemory(t *testing.T) {
+	ctx := context.Background()
+	backend := NewMemoryCache(1 << 20)
+	tiered := NewTieredCache(1<<20, time.Minute, backend)
+
+	if err := backend.Put(ctx, "cache/a.json", []byte("a"), nil); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, _, err := tiered.Get(ctx, "cache/a.json"); err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+	}
+	if _, _, err := tiered.Get(ctx, "cache/missing.json"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get of a missing key returned %v, want ErrNotFound", err)
+	}
+
+	stats := tiered.Stats()
+	if stats.BackendHits != 1 || stats.MemoryHits != 2 || stats.Misses != 1 {
+		t.Errorf("stats = %+v, want 1 backend hit, 2 memory hits and 1 miss", stats)
+	}
+	if stats.MemoryObjects != 1 || stats.MemoryBytes != 1 {
+		t.Errorf("memory usage = %d objects, %d bytes, want 1 and 1", stats.MemoryObjects, stats.MemoryBytes)
+	}
+}
+
+// TestTieredCacheOtherInstance has two instances share a backend, changes one makes reach the other once
+// the memory tier TTL passes
+func TestTieredCacheOtherInstance(t *testing.T) {
+	const ttl = 50 * time.Millisecond
+	ctx := context.Background()
+	backend := NewMemoryCache(1 << 20)
+	first := NewTieredCache(1<<20, ttl, backend)
+	second := NewTieredCache(1<<20, ttl, backend)
+
+	if err := first.Put(ctx, "cache/a.json", []byte("old"), nil); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if data, _, err := second.Get(ctx, "cache/a.json"); err != nil || string(data) != "old" {
+		t.Fatalf("Get returned %q, %v, want old", data, err)
+	}
+
+	// A refresh on the first instance
+	if err := first.Put(ctx, "cache/a.json", []byte("new"), nil); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if data, _, _ := second.Get(ctx, "cache/a.json"); string(data) != "old" {
+		t.Fatalf("Get within the TTL returned %q, want the memory copy", data)
+	}
+	time.Sleep(2 * ttl)
+	if data, _, err := second.Get(ctx, "cache/a.json"); err != nil || string(data) != "new" {
+		t.Errorf("Get after the TTL returned %q, %v, want new", data, err)
+	}
+
+	// A purge on the first instance
+	if err := first.Delete(ctx, "cache/a.json"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	time.Sleep(2 * ttl)
+	if _, _, err := second.Get(ctx, "cache/a.json"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get after a purge and the TTL returned %v, want ErrNotFound", err)
+	}
+	if objects, _ := second.memory.Usage(); objects != 0 {
+		t.Errorf("memory tier still holds %d objects after the purge", objects)
+	}
+}